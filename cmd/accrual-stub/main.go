@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-diplom-56/internal/accrualstub"
+)
+
+func main() {
+	var (
+		address    string
+		scriptPath string
+		rateLimit  int
+	)
+	flag.StringVar(&address, "a", "localhost:8081", "HTTP server address")
+	flag.StringVar(&scriptPath, "s", "", "Path to YAML script with per-order responses")
+	flag.IntVar(&rateLimit, "l", -1, "Requests per minute limit, overrides the script; 0 disables it")
+	flag.Parse()
+
+	script := &accrualstub.Script{}
+	if scriptPath != "" {
+		var err error
+		script, err = accrualstub.LoadScript(scriptPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if rateLimit >= 0 {
+		script.RateLimit = rateLimit
+	}
+
+	router := gin.Default()
+	accrualstub.NewStub(script).InitRoutes(router)
+
+	srv := &http.Server{
+		Addr:    address,
+		Handler: router,
+	}
+	go func() {
+		log.Printf("accrual stub listening at: %s", address)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("error occurred while running http server: %s", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 2)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Fatalf("accrual stub forced to shutdown: %s", err)
+	}
+}
@@ -10,11 +10,14 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
 	"github.com/ypxd99/yandex-diplom-56/internal/repository"
+	"github.com/ypxd99/yandex-diplom-56/internal/repository/memory"
 	"github.com/ypxd99/yandex-diplom-56/internal/repository/postgres"
 	"github.com/ypxd99/yandex-diplom-56/internal/server"
 	"github.com/ypxd99/yandex-diplom-56/internal/service"
 	"github.com/ypxd99/yandex-diplom-56/internal/transport/handler"
+	"github.com/ypxd99/yandex-diplom-56/internal/worker"
 	"github.com/ypxd99/yandex-diplom-56/util"
 )
 
@@ -24,22 +27,42 @@ func main() {
 	logger := util.GetLogger()
 	logger.Info("start gophermart service")
 
-	if cfg.Postgres.MakeMigration {
-		go makeMegrations()
+	if err := middleware.SetSigningKeys(cfg.Auth.Keys); err != nil {
+		logger.Fatalf("Failed to load token keys: %v", err)
 	}
 
-	var (
-		repo repository.GophermartRepo
-		err  error
-	)
-	postgresRepo, err := postgres.Connect(context.Background())
-	if err != nil {
-		logger.Fatalf("Failed to initialize Postgres: %v", err)
+	var repo repository.GophermartRepo
+	if cfg.Postgres.ConnString != "" {
+		postgresRepo, err := postgres.Connect(context.Background())
+		if err != nil {
+			logger.Fatalf("Failed to initialize Postgres: %v", err)
+		}
+
+		if cfg.Postgres.MakeMigration {
+			logger.Info("start migrations")
+			if err = postgresRepo.MigrateDBUp(context.Background()); err != nil {
+				logger.Fatalf("Failed to apply migrations: %v", err)
+			}
+			logger.Info("migrations up")
+		}
+		repo = postgresRepo
+	} else {
+		logger.Warn("DATABASE_URI is not set, using in-memory storage")
+		repo = memory.New()
 	}
-	repo = postgresRepo
 	defer repo.Close()
 
+	pool := worker.NewPool(repo)
+	pool.Start(context.Background())
+
 	service := service.InitService(repo)
+	if err := service.PromoteAdmins(context.Background(), cfg.Auth.AdminLogins); err != nil {
+		logger.Fatalf("Failed to promote admins: %v", err)
+	}
+	pruneCtx, stopPruning := context.WithCancel(context.Background())
+	defer stopPruning()
+	go pruneLoginAttempts(pruneCtx, service, time.Duration(cfg.Auth.Lockout.Window)*time.Second)
+
 	h := handler.InitHandler(service)
 
 	router := gin.Default()
@@ -55,32 +78,59 @@ func main() {
 		}
 	}()
 
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go reloadSigningKeys(reload)
+
 	quit := make(chan os.Signal, 2)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := srv.Stop(ctx); err != nil {
-		util.GetLogger().Fatalf("Server forced to shutdown: %s", err.Error())
+	stopErr := srv.Stop(ctx)
+	if stopErr != nil {
+		util.GetLogger().Errorf("Server forced to shutdown: %s", stopErr.Error())
+	}
+	// The pool is stopped even after a forced shutdown, so its leased jobs
+	// are handed back instead of being held until the visibility timeout.
+	pool.Stop()
+	if stopErr != nil {
+		repo.Close()
+		os.Exit(1)
 	}
 	util.GetLogger().Log(4, "HTTP GOPHERMART service stopped")
 }
 
-func makeMegrations() {
-	// migrate UP
-	util.GetLogger().Info("start migrations")
-	err := postgres.MigrateDBUp(context.Background())
-	if err != nil {
-		util.GetLogger().Error(err)
-		return
+// pruneLoginAttempts deletes the expired login failure counters once per
+// lockout window, so counters of logins that never come back don't pile up.
+func pruneLoginAttempts(ctx context.Context, svc service.GophermartService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := svc.PruneLoginAttempts(ctx); err != nil {
+				util.GetLogger().Errorf("failed to prune login attempts: %v", err)
+			}
+		}
+	}
+}
+
+// reloadSigningKeys re-reads the token keyset on SIGHUP, so a new key can be
+// added or promoted without a restart.
+func reloadSigningKeys(reload <-chan os.Signal) {
+	for range reload {
+		keys, err := util.LoadAuthKeys()
+		if err != nil {
+			util.GetLogger().Errorf("failed to reload token keys: %v", err)
+			continue
+		}
+		if err = middleware.SetSigningKeys(keys); err != nil {
+			util.GetLogger().Errorf("failed to reload token keys: %v", err)
+		}
 	}
-	util.GetLogger().Info("migrations up")
-
-	// migrate DOWN
-	//err = postgres.MigrateDBDown(context.Background())
-	//if err != nil {
-	//	util.GetLogger().Error(err)
-	//	return
-	//}
 }
@@ -0,0 +1,133 @@
+package accrual
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker stops calls to the accrual system after threshold consecutive
+// failures. After openTimeout it lets a single probe through: success closes
+// the breaker, failure opens it again.
+type Breaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	state       BreakerState
+	failures    int
+	openedAt    time.Time
+	probing     bool
+	now         func() time.Time
+}
+
+func NewBreaker(threshold int, openTimeout time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	breakerState.Set(float64(StateClosed))
+
+	return &Breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Allow reports whether a call may be made. Every allowed call must be
+// followed by Success, Failure or Cancel.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != StateClosed {
+		b.setState(StateClosed)
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.setState(StateOpen)
+	}
+}
+
+// Cancel releases a call that ended without telling anything about the
+// health of the accrual system, e.g. because the caller gave up.
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// setState must be called with the lock held.
+func (b *Breaker) setState(state BreakerState) {
+	b.state = state
+	breakerState.Set(float64(state))
+	breakerTransitions.WithLabelValues(state.String()).Inc()
+
+	if state == StateOpen {
+		util.GetLogger().Warnf("accrual circuit breaker open after %d failures", b.failures)
+	} else {
+		util.GetLogger().Infof("accrual circuit breaker %s", state)
+	}
+}
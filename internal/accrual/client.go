@@ -0,0 +1,163 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+const (
+	defaultRetryAfter = 60 * time.Second
+
+	// maxErrorBody bounds how much of a 429 body is read to find the limit.
+	maxErrorBody = 1 << 10
+)
+
+var (
+	ErrOrderNotRegistered = errors.New("order is not registered in accrual system")
+	ErrCircuitOpen        = errors.New("accrual system circuit breaker is open")
+
+	limitPattern = regexp.MustCompile(`No more than (\d+) requests per minute`)
+)
+
+// RateLimitError is returned on 429. The limiter has already been adjusted
+// and paused, so the caller only needs to retry later.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Limit      int
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("accrual system rate limit exceeded, retry after %s", e.RetryAfter)
+}
+
+// Client talks to the accrual system. It is safe for concurrent use; all
+// callers share its rate limiter and circuit breaker.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	limiter    *Limiter
+	breaker    *Breaker
+}
+
+func NewClient(cfg util.Accrual) *Client {
+	requestTimeout := time.Duration(cfg.RequestTimeout) * time.Second
+	connectTimeout := time.Duration(cfg.ConnectTimeout) * time.Second
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = requestTimeout
+
+	return &Client{
+		baseURL: strings.TrimRight(cfg.Address, "/"),
+		httpClient: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: transport,
+		},
+		limiter: NewLimiter(cfg.RateLimit),
+		breaker: NewBreaker(cfg.Breaker.Threshold, time.Duration(cfg.Breaker.OpenTimeout)*time.Second),
+	}
+}
+
+// GetOrder returns the accrual of an order. It waits for the rate limiter
+// and fails fast with ErrCircuitOpen while the accrual system is down.
+func (c *Client) GetOrder(ctx context.Context, number string) (*model.AccrualOrder, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	if !c.breaker.Allow() {
+		requestsTotal.WithLabelValues("circuit_open").Inc()
+		return nil, ErrCircuitOpen
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/orders/"+url.PathEscape(number), nil)
+	if err != nil {
+		c.breaker.Cancel()
+		return nil, errors.WithMessage(err, "error occurred while creating accrual request")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.breaker.Cancel()
+			return nil, ctx.Err()
+		}
+		c.breaker.Failure()
+		requestsTotal.WithLabelValues("error").Inc()
+		return nil, errors.WithMessage(err, "error occurred while requesting accrual system")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		c.breaker.Failure()
+	} else {
+		c.breaker.Success()
+	}
+	requestsTotal.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var order model.AccrualOrder
+		if err = json.NewDecoder(resp.Body).Decode(&order); err != nil {
+			return nil, errors.WithMessage(err, "error occurred while decoding accrual response")
+		}
+		return &order, nil
+	case http.StatusNoContent:
+		return nil, ErrOrderNotRegistered
+	case http.StatusTooManyRequests:
+		return nil, c.rateLimited(resp)
+	default:
+		return nil, errors.Errorf("unexpected accrual system status: %d", resp.StatusCode)
+	}
+}
+
+// Capacity returns how many requests the rate limiter lets through within d.
+func (c *Client) Capacity(d time.Duration) int {
+	return c.limiter.Capacity(d)
+}
+
+// rateLimited pauses the limiter for Retry-After and adopts the limit stated
+// in the response body.
+func (c *Client) rateLimited(resp *http.Response) *RateLimitError {
+	rlErr := &RateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if m := limitPattern.FindSubmatch(body); m != nil {
+		if limit, err := strconv.Atoi(string(m[1])); err == nil && limit > 0 {
+			rlErr.Limit = limit
+			if limit != c.limiter.Limit() {
+				util.GetLogger().Warnf("accrual system allows %d requests per minute, adjusting rate limit", limit)
+				c.limiter.SetLimit(limit)
+			}
+		}
+	}
+	c.limiter.Pause(rlErr.RetryAfter)
+
+	return rlErr
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+
+	return defaultRetryAfter
+}
@@ -0,0 +1,154 @@
+package accrual
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+func TestMain(m *testing.M) {
+	util.InitLogger(util.LoggerCfg{Level: logrus.ErrorLevel})
+	os.Exit(m.Run())
+}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return NewClient(util.Accrual{
+		Address:        srv.URL,
+		RequestTimeout: 1,
+		ConnectTimeout: 1,
+		Breaker:        util.Breaker{Threshold: 2, OpenTimeout: 30},
+	})
+}
+
+func TestClientAdaptsToRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("No more than 120 requests per minute allowed"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order":"1","status":"PROCESSED","accrual":500}`))
+	})
+
+	_, err := c.GetOrder(context.Background(), "1")
+	var rlErr *RateLimitError
+	require.ErrorAs(t, err, &rlErr)
+	assert.Equal(t, 120, rlErr.Limit)
+	assert.Equal(t, time.Second, rlErr.RetryAfter)
+	assert.Equal(t, 120, c.limiter.Limit())
+	assert.Equal(t, StateClosed, c.breaker.State(), "429 is not a failure")
+
+	start := time.Now()
+	order, err := c.GetOrder(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, model.AccrualStatusProcessed, order.Status)
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond, "waits for Retry-After")
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	var calls, failing atomic.Int32
+	failing.Store(1)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := c.GetOrder(context.Background(), "1")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+	assert.Equal(t, StateOpen, c.breaker.State())
+
+	_, err := c.GetOrder(context.Background(), "1")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(2), calls.Load(), "open breaker fails fast")
+
+	now = now.Add(30 * time.Second)
+	_, err = c.GetOrder(context.Background(), "1")
+	require.Error(t, err)
+	assert.Equal(t, StateOpen, c.breaker.State(), "failed probe opens the breaker again")
+
+	now = now.Add(30 * time.Second)
+	failing.Store(0)
+	_, err = c.GetOrder(context.Background(), "1")
+	assert.ErrorIs(t, err, ErrOrderNotRegistered)
+	assert.Equal(t, StateClosed, c.breaker.State())
+}
+
+func TestLimiterSpreadsRequests(t *testing.T) {
+	l := NewLimiter(60)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.SetLimit(60)
+
+	assert.Zero(t, l.reserve())
+	assert.Equal(t, time.Second, l.reserve())
+
+	now = now.Add(time.Second)
+	assert.Zero(t, l.reserve())
+
+	l.Pause(5 * time.Second)
+	assert.Equal(t, 5*time.Second, l.reserve())
+
+	l.SetLimit(0)
+	now = now.Add(5 * time.Second)
+	assert.Zero(t, l.reserve())
+	assert.Zero(t, l.reserve(), "no limit")
+}
+
+func TestLimiterCapacity(t *testing.T) {
+	l := NewLimiter(60)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.SetLimit(60)
+
+	assert.Equal(t, 26, l.Capacity(25*time.Second), "the bucket token plus one per second")
+	assert.Zero(t, l.reserve())
+	assert.Equal(t, 25, l.Capacity(25*time.Second))
+
+	l.Pause(20 * time.Second)
+	assert.Equal(t, 5, l.Capacity(25*time.Second), "paused time doesn't count")
+	l.Pause(time.Minute)
+	assert.Zero(t, l.Capacity(25*time.Second), "paused for longer than the lease")
+
+	l.SetLimit(0)
+	now = now.Add(time.Minute)
+	assert.Equal(t, math.MaxInt32, l.Capacity(25*time.Second), "no limit")
+}
+
+func TestLimiterWaitCancelled(t *testing.T) {
+	l := NewLimiter(60)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.SetLimit(60)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, l.Wait(ctx), context.Canceled)
+	assert.InDelta(t, 1, l.tokens, 0.001, "a cancelled wait keeps the token")
+	assert.NoError(t, l.Wait(context.Background()))
+}
@@ -0,0 +1,145 @@
+package accrual
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket shared by every caller of the accrual system.
+// It starts at the configured rate, or unlimited, and adapts to the limit
+// the accrual system reports in 429 responses. A Retry-After pauses it
+// entirely.
+type Limiter struct {
+	mu          sync.Mutex
+	perMinute   int
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	now         func() time.Time
+}
+
+// NewLimiter creates a limiter allowing perMinute requests, 0 means no limit
+// until the accrual system reports one.
+func NewLimiter(perMinute int) *Limiter {
+	l := &Limiter{now: time.Now}
+	l.SetLimit(perMinute)
+
+	return l
+}
+
+// SetLimit changes the rate. The bucket holds a single token, so requests
+// are spread evenly over the minute instead of being sent in a burst.
+func (l *Limiter) SetLimit(perMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if perMinute < 0 {
+		perMinute = 0
+	}
+	l.perMinute = perMinute
+	l.tokens = 1
+	l.last = l.now()
+	limiterRate.Set(float64(perMinute))
+}
+
+// Limit returns the current rate in requests per minute, 0 means no limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.perMinute
+}
+
+// Capacity returns how many requests may be sent within d from now, taking
+// a pause into account. Without a limit it returns math.MaxInt32.
+func (l *Limiter) Capacity(d time.Duration) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Before(l.pausedUntil) {
+		d -= l.pausedUntil.Sub(now)
+	}
+	if d <= 0 {
+		return 0
+	}
+	if l.perMinute == 0 {
+		return math.MaxInt32
+	}
+
+	rate := float64(l.perMinute) / time.Minute.Seconds()
+	tokens := l.tokens + now.Sub(l.last).Seconds()*rate
+	if tokens > 1 {
+		tokens = 1
+	}
+
+	return int(tokens + d.Seconds()*rate)
+}
+
+// Pause blocks every caller for d.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := l.now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done. A token is only
+// taken while ctx is still live, so cancelled requests don't use up the rate.
+func (l *Limiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	defer func() {
+		limiterWait.Observe(time.Since(start).Seconds())
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		wait := l.reserve()
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes a token and returns 0, or returns how long to wait before
+// trying again.
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.perMinute == 0 {
+		return 0
+	}
+
+	rate := float64(l.perMinute) / time.Minute.Seconds()
+	l.tokens += now.Sub(l.last).Seconds() * rate
+	if l.tokens > 1 {
+		l.tokens = 1
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / rate * float64(time.Second))
+}
@@ -0,0 +1,46 @@
+package accrual
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The metrics are registered in the default registry served by
+// util.GetMetricsRoute.
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gophermart",
+		Subsystem: "accrual",
+		Name:      "requests_total",
+		Help:      "Requests to the accrual system by result.",
+	}, []string{"result"})
+
+	breakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "gophermart",
+		Subsystem: "accrual",
+		Name:      "breaker_state",
+		Help:      "Circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	})
+
+	breakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "gophermart",
+		Subsystem: "accrual",
+		Name:      "breaker_transitions_total",
+		Help:      "Circuit breaker state changes by new state.",
+	}, []string{"state"})
+
+	limiterRate = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "gophermart",
+		Subsystem: "accrual",
+		Name:      "limiter_rate_per_minute",
+		Help:      "Current request rate limit, 0 means unlimited.",
+	})
+
+	limiterWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "gophermart",
+		Subsystem: "accrual",
+		Name:      "limiter_wait_seconds",
+		Help:      "Time requests waited for the rate limiter.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+)
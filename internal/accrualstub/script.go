@@ -0,0 +1,62 @@
+package accrualstub
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Script describes how the stub answers for each order.
+type Script struct {
+	// RateLimit is the number of requests allowed per minute, 0 disables it.
+	RateLimit int `yaml:"RateLimit"`
+	// Default is used for orders that are not listed in Orders.
+	Default OrderScript            `yaml:"Default"`
+	Orders  map[string]OrderScript `yaml:"Orders"`
+}
+
+// OrderScript is a timeline of responses. The clock for an order starts at
+// its first request, and the step with the latest After that has elapsed is
+// served, whatever the order of Steps.
+type OrderScript struct {
+	Steps []Step `yaml:"Steps"`
+}
+
+type Step struct {
+	After   time.Duration       `yaml:"After"`
+	Code    int                 `yaml:"Code"`
+	Status  model.AccrualStatus `yaml:"Status"`
+	Accrual *float64            `yaml:"Accrual"`
+}
+
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while reading script")
+	}
+
+	var script Script
+	if err = yaml.Unmarshal(data, &script); err != nil {
+		return nil, errors.WithMessage(err, "error occurred while parsing script")
+	}
+
+	return &script, nil
+}
+
+func (s OrderScript) stepAt(elapsed time.Duration) (Step, bool) {
+	var (
+		current Step
+		found   bool
+	)
+	for _, step := range s.Steps {
+		if step.After <= elapsed && (!found || step.After >= current.After) {
+			current = step
+			found = true
+		}
+	}
+
+	return current, found
+}
@@ -0,0 +1,121 @@
+package accrualstub
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+// Stub is a scriptable stand-in for the accrual system.
+type Stub struct {
+	script *Script
+	now    func() time.Time
+
+	mu          sync.Mutex
+	firstSeen   map[string]time.Time
+	windowStart time.Time
+	requests    int
+}
+
+func NewStub(script *Script) *Stub {
+	if script.Orders == nil {
+		script.Orders = make(map[string]OrderScript)
+	}
+
+	return &Stub{
+		script:    script,
+		now:       time.Now,
+		firstSeen: make(map[string]time.Time),
+	}
+}
+
+// SetOrder replaces the script of a single order and restarts its clock.
+func (s *Stub) SetOrder(number string, script OrderScript) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.script.Orders[number] = script
+	delete(s.firstSeen, number)
+}
+
+func (s *Stub) InitRoutes(r *gin.Engine) {
+	r.GET("/api/orders/:number", s.getOrder)
+}
+
+func (s *Stub) getOrder(c *gin.Context) {
+	number := c.Param("number")
+
+	step, limited := s.next(number)
+	if limited != nil {
+		c.Header("Retry-After", strconv.Itoa(int(limited.retryAfter.Seconds())))
+		c.String(http.StatusTooManyRequests, fmt.Sprintf("No more than %d requests per minute allowed", limited.limit))
+		return
+	}
+
+	code := step.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if code != http.StatusOK {
+		c.Status(code)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.AccrualOrder{
+		Order:   number,
+		Status:  step.Status,
+		Accrual: step.Accrual,
+	})
+}
+
+// rateLimited describes a rejected request.
+type rateLimited struct {
+	limit      int
+	retryAfter time.Duration
+}
+
+// next applies the rate limit and resolves the step to serve. When the
+// limit is exceeded it reports the limit and how long the caller should wait.
+func (s *Stub) next(number string) (Step, *rateLimited) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.script.RateLimit > 0 {
+		if now.Sub(s.windowStart) >= time.Minute {
+			s.windowStart = now
+			s.requests = 0
+		}
+		s.requests++
+		if s.requests > s.script.RateLimit {
+			retryAfter := s.windowStart.Add(time.Minute).Sub(now).Round(time.Second)
+			if retryAfter < time.Second {
+				retryAfter = time.Second
+			}
+			return Step{}, &rateLimited{limit: s.script.RateLimit, retryAfter: retryAfter}
+		}
+	}
+
+	first, seen := s.firstSeen[number]
+	if !seen {
+		first = now
+		s.firstSeen[number] = now
+	}
+
+	orderScript, listed := s.script.Orders[number]
+	if !listed {
+		orderScript = s.script.Default
+	}
+
+	step, found := orderScript.stepAt(now.Sub(first))
+	if !found {
+		return Step{Code: http.StatusNoContent}, nil
+	}
+
+	return step, nil
+}
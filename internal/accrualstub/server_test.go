@@ -0,0 +1,100 @@
+package accrualstub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+type testStub struct {
+	*Stub
+	router *gin.Engine
+	clock  time.Time
+}
+
+func newTestStub(script *Script) *testStub {
+	gin.SetMode(gin.TestMode)
+	ts := &testStub{Stub: NewStub(script), router: gin.New(), clock: time.Now()}
+	ts.now = func() time.Time { return ts.clock }
+	ts.InitRoutes(ts.router)
+
+	return ts
+}
+
+func (ts *testStub) get(number string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ts.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/orders/"+number, nil))
+
+	return w
+}
+
+func decodeOrder(t *testing.T, w *httptest.ResponseRecorder) model.AccrualOrder {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code)
+	var order model.AccrualOrder
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+
+	return order
+}
+
+func TestStubScriptProgression(t *testing.T) {
+	accrual := 500.0
+	ts := newTestStub(&Script{})
+	// Steps are deliberately out of order.
+	ts.SetOrder("1", OrderScript{Steps: []Step{
+		{After: 2 * time.Second, Status: model.AccrualStatusProcessed, Accrual: &accrual},
+		{Status: model.AccrualStatusRegistered},
+		{After: time.Second, Status: model.AccrualStatusProcessing},
+	}})
+
+	assert.Equal(t, model.AccrualStatusRegistered, decodeOrder(t, ts.get("1")).Status)
+
+	ts.clock = ts.clock.Add(time.Second)
+	assert.Equal(t, model.AccrualStatusProcessing, decodeOrder(t, ts.get("1")).Status)
+
+	ts.clock = ts.clock.Add(time.Second)
+	order := decodeOrder(t, ts.get("1"))
+	assert.Equal(t, model.AccrualStatusProcessed, order.Status)
+	require.NotNil(t, order.Accrual)
+	assert.InDelta(t, accrual, *order.Accrual, 0.001)
+
+	assert.Equal(t, http.StatusNoContent, ts.get("2").Code, "unlisted order without a default")
+}
+
+func TestStubScriptCode(t *testing.T) {
+	ts := newTestStub(&Script{Default: OrderScript{Steps: []Step{
+		{Code: http.StatusInternalServerError},
+		{After: time.Second, Status: model.AccrualStatusInvalid},
+	}}})
+
+	assert.Equal(t, http.StatusInternalServerError, ts.get("1").Code)
+
+	ts.clock = ts.clock.Add(time.Second)
+	assert.Equal(t, model.AccrualStatusInvalid, decodeOrder(t, ts.get("1")).Status)
+	assert.Equal(t, http.StatusInternalServerError, ts.get("2").Code, "clock starts at the first request")
+}
+
+func TestStubRateLimit(t *testing.T) {
+	ts := newTestStub(&Script{RateLimit: 2, Default: OrderScript{Steps: []Step{
+		{Status: model.AccrualStatusRegistered},
+	}}})
+
+	assert.Equal(t, http.StatusOK, ts.get("1").Code)
+	ts.clock = ts.clock.Add(20 * time.Second)
+	assert.Equal(t, http.StatusOK, ts.get("2").Code)
+
+	w := ts.get("3")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "40", w.Header().Get("Retry-After"))
+	assert.Equal(t, "No more than 2 requests per minute allowed", w.Body.String())
+
+	ts.clock = ts.clock.Add(40 * time.Second)
+	assert.Equal(t, http.StatusOK, ts.get("3").Code, "window restarts after a minute")
+}
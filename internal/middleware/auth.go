@@ -1,176 +1,317 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
 	"github.com/ypxd99/yandex-diplom-56/util"
 )
 
+const (
+	sessionKey   = "session_id"
+	roleKey      = "role"
+	authErrorKey = "auth_error"
+
+	bearerScheme = "Bearer"
+	authRealm    = "gophermart"
+
+	// purposePreAuth marks the token issued after the password step of a
+	// two-step login. It only unlocks the second step.
+	purposePreAuth   = "pre_auth"
+	preAuthCookie    = "pre_auth_token"
+	preAuthCookieURI = "/api/user/login"
+
+	accessCookieURI  = "/"
+	refreshCookieURI = "/api/user"
+)
+
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID       string     `json:"user_id"`
+	SessionID    string     `json:"sid"`
+	Role         model.Role `json:"role"`
+	TokenVersion int        `json:"ver"`
+	Purpose      string     `json:"pur,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		cookieName := util.GetConfig().Auth.CookieName
-		cookie, err := c.Cookie(cookieName)
-		logger := util.GetLogger()
-
-		cfg := util.GetConfig()
-		secretKey := []byte(cfg.Auth.SecretKey)
-
-		if err != nil || !isValidToken(cookie, secretKey) {
-			newUserID := uuid.New()
-			userIDStr := newUserID.String()
+// SessionChecker reports whether a session has not been revoked and the
+// token version is still current.
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, userID, sessionID uuid.UUID, tokenVersion int) (bool, error)
+}
 
-			token, err := generateToken(userIDStr, secretKey)
-			if err != nil {
-				logger.Errorf("failed to generate token: %v", err)
-				c.AbortWithStatus(http.StatusInternalServerError)
-				return
-			}
+// AuthMiddleware resolves the caller from the `Authorization: Bearer` header
+// or, if there is none, from the access token cookie. Requests without a
+// valid token, or with a token of a revoked session, pass through
+// anonymously and are rejected by RequireAuth. Requests carrying an API key
+// are left to APIKeyMiddleware.
+func AuthMiddleware(sessions SessionChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(APIKeyHeader) != "" {
+			c.Next()
+			return
+		}
 
-			c.SetCookie(
-				cookieName,
-				token,
-				3600*24*30,
-				"/",
-				"",
-				false,
-				true,
-			)
-
-			c.Set(cookieName, newUserID)
-			logger.Infof("created new user with ID: %s", userIDStr)
-		} else {
-			userIDStr, err := extractUserIDFromToken(cookie, secretKey)
-			if err != nil {
-				newUserID := uuid.New()
-				userIDStr = newUserID.String()
-
-				token, err := generateToken(userIDStr, secretKey)
-				if err != nil {
-					logger.Errorf("failed to generate token: %v", err)
-					c.AbortWithStatus(http.StatusInternalServerError)
-					return
-				}
-
-				c.SetCookie(
-					cookieName,
-					token,
-					3600*24*30,
-					"/",
-					"",
-					false,
-					true,
-				)
-
-				c.Set(cookieName, newUserID)
-				logger.Infof("regenerated token for user, new ID: %s", userIDStr)
+		cfg := util.GetConfig().Auth
+		token, ok := BearerToken(c)
+		if !ok {
+			cookie, err := c.Cookie(cfg.CookieName)
+			if err != nil || cookie == "" {
 				c.Next()
 				return
 			}
+			token = cookie
+		}
 
-			userID, err := uuid.Parse(userIDStr)
-			if err != nil {
-				userID = uuid.New()
-				userIDStr = userID.String()
-
-				token, err := generateToken(userIDStr, secretKey)
-				if err != nil {
-					logger.Errorf("failed to generate token: %v", err)
-					c.AbortWithStatus(http.StatusInternalServerError)
-					return
-				}
-
-				c.SetCookie(
-					cookieName,
-					token,
-					3600*24*30,
-					"/",
-					"",
-					false,
-					true,
-				)
-
-				logger.Infof("regenerated UUID for user, new ID: %s", userIDStr)
-			}
+		claims, err := parseToken(token)
+		if err != nil || claims.Purpose != "" {
+			c.Set(authErrorKey, true)
+			c.Next()
+			return
+		}
 
-			c.Set(cookieName, userID)
-			logger.Infof("authenticated user with ID: %s", userIDStr)
+		userID, err := uuid.Parse(claims.UserID)
+		if err != nil {
+			c.Set(authErrorKey, true)
+			c.Next()
+			return
+		}
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			c.Set(authErrorKey, true)
+			c.Next()
+			return
+		}
+
+		active, err := sessions.IsSessionActive(c.Request.Context(), userID, sessionID, claims.TokenVersion)
+		if err != nil {
+			util.GetLogger().Errorf("failed to check session %s: %v", sessionID, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			c.Set(authErrorKey, true)
+			c.Next()
+			return
 		}
 
+		c.Set(cfg.CookieName, userID)
+		c.Set(sessionKey, sessionID)
+		c.Set(roleKey, claims.Role)
 		c.Next()
 	}
 }
 
-func generateToken(userID string, key []byte) (string, error) {
-	claims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * 24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// BearerToken returns the token from the `Authorization: Bearer` header.
+func BearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, bearerScheme) {
+		return "", false
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
 
-	tokenString, err := token.SignedString(key)
+// SetAuthCookie issues a short-lived access token for the session and
+// attaches it together with the refresh token to the response. The returned
+// tokens may additionally be sent in the response body.
+func SetAuthCookie(c *gin.Context, session *model.IssuedSession) (*model.TokenResponse, error) {
+	cfg := util.GetConfig().Auth
+	accessTTL := time.Duration(cfg.AccessTokenTTL) * time.Second
+
+	token, err := generateToken(&Claims{
+		UserID:       session.UserID.String(),
+		SessionID:    session.SessionID.String(),
+		Role:         session.Role,
+		TokenVersion: session.TokenVersion,
+	}, accessTTL)
 	if err != nil {
-		return "", err
+		return nil, errors.WithMessage(err, "failed to generate token")
 	}
 
-	return tokenString, nil
+	refreshMaxAge := int(time.Until(session.RefreshExpiresAt).Seconds())
+	setCookie(c, cfg.CookieName, token, int(accessTTL.Seconds()), accessCookieURI)
+	setCookie(c, cfg.RefreshCookieName, session.RefreshToken, refreshMaxAge, refreshCookieURI)
+	if _, err = setCSRFCookie(c, refreshMaxAge); err != nil {
+		return nil, err
+	}
+	c.Header("Authorization", bearerScheme+" "+token)
+	c.Set(cfg.CookieName, session.UserID)
+	c.Set(sessionKey, session.SessionID)
+	c.Set(roleKey, session.Role)
+
+	return &model.TokenResponse{
+		TokenType:        bearerScheme,
+		AccessToken:      token,
+		ExpiresIn:        cfg.AccessTokenTTL,
+		RefreshToken:     session.RefreshToken,
+		RefreshExpiresAt: session.RefreshExpiresAt,
+	}, nil
 }
 
-func isValidToken(tokenString string, key []byte) bool {
-	_, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return key, nil
-	})
+// ClearAuthCookies removes the access, refresh and CSRF token cookies.
+func ClearAuthCookies(c *gin.Context) {
+	cfg := util.GetConfig().Auth
+	setCookie(c, cfg.CookieName, "", -1, accessCookieURI)
+	setCookie(c, cfg.RefreshCookieName, "", -1, refreshCookieURI)
+	writeCookie(c, CSRFCookie, "", -1, accessCookieURI, false)
+}
+
+// GetRefreshToken returns the refresh token sent in the refresh cookie.
+func GetRefreshToken(c *gin.Context) string {
+	token, err := c.Cookie(util.GetConfig().Auth.RefreshCookieName)
+	if err != nil {
+		return ""
+	}
+
+	return token
+}
+
+// IssuePreAuthToken hands out the token for the second step of a two-step
+// login, both in a cookie scoped to the login routes and in the body.
+func IssuePreAuthToken(c *gin.Context, userID uuid.UUID) (*model.PreAuthResponse, error) {
+	ttl := util.GetConfig().Auth.PreAuthTokenTTL
+	token, err := generateToken(&Claims{
+		UserID:  userID.String(),
+		Purpose: purposePreAuth,
+	}, time.Duration(ttl)*time.Second)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to generate pre-auth token")
+	}
+
+	setCookie(c, preAuthCookie, token, int(ttl), preAuthCookieURI)
+
+	return &model.PreAuthResponse{PreAuthToken: token, ExpiresIn: ttl}, nil
+}
+
+// GetPreAuthUserID returns the user of the pre-auth token given in the body,
+// the `Authorization: Bearer` header or the pre-auth cookie.
+func GetPreAuthUserID(c *gin.Context, bodyToken string) (uuid.UUID, error) {
+	token := bodyToken
+	if token == "" {
+		token, _ = BearerToken(c)
+	}
+	if token == "" {
+		token, _ = c.Cookie(preAuthCookie)
+	}
+	if token == "" {
+		return uuid.Nil, model.ErrInvalidPreAuthToken
+	}
+
+	claims, err := parseToken(token)
+	if err != nil || claims.Purpose != purposePreAuth {
+		return uuid.Nil, model.ErrInvalidPreAuthToken
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return uuid.Nil, model.ErrInvalidPreAuthToken
+	}
+
+	return userID, nil
+}
+
+// ClearPreAuthToken removes the pre-auth cookie after the second step.
+func ClearPreAuthToken(c *gin.Context) {
+	setCookie(c, preAuthCookie, "", -1, preAuthCookieURI)
+}
+
+// setCookie sets an HTTP-only cookie with the configured Secure, SameSite
+// and Domain attributes.
+func setCookie(c *gin.Context, name, value string, maxAge int, path string) {
+	writeCookie(c, name, value, maxAge, path, true)
+}
 
-	return err == nil
+func writeCookie(c *gin.Context, name, value string, maxAge int, path string, httpOnly bool) {
+	cfg := util.GetConfig().Auth.Cookie
+
+	c.SetSameSite(sameSiteMode(cfg.SameSite))
+	c.SetCookie(name, value, maxAge, path, cfg.Domain, cfg.Secure, httpOnly)
+}
+
+func sameSiteMode(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case util.SameSiteStrict:
+		return http.SameSiteStrictMode
+	case util.SameSiteNone:
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
 }
 
-func extractUserIDFromToken(tokenString string, key []byte) (string, error) {
+func generateToken(claims *Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	key, err := signingKeys.signing(now)
+	if err != nil {
+		return "", err
+	}
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	tokenString, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func parseToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, err := signingKeys.verification(kid, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, errors.New("unexpected signing method")
 		}
-		return key, nil
+		return key.verifyKey, nil
 	})
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.UserID, nil
+		return claims, nil
 	}
 
-	return "", errors.New("invalid token claims")
+	return nil, errors.New("invalid token claims")
 }
 
+// RequireAuth rejects anonymous requests with 401 and a WWW-Authenticate
+// challenge, which reports invalid_token when a token was sent but rejected.
 func RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		cookieName := util.GetConfig().Auth.CookieName
 		userID, exists := c.Get(cookieName)
 		if !exists {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			abortUnauthorized(c)
 			return
 		}
 
 		_, ok := userID.(uuid.UUID)
 		if !ok {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			abortUnauthorized(c)
 			return
 		}
 
@@ -178,6 +319,37 @@ func RequireAuth() gin.HandlerFunc {
 	}
 }
 
+// RequireRole lets through only callers whose token carries one of the
+// roles. It must run after RequireAuth.
+func RequireRole(roles ...model.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := GetRole(c)
+		if !ok {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
+func abortUnauthorized(c *gin.Context) {
+	challenge := bearerScheme + ` realm="` + authRealm + `"`
+	if c.GetBool(authErrorKey) {
+		challenge += `, error="invalid_token"`
+	}
+
+	c.Header("WWW-Authenticate", challenge)
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
+
 func GetUserID(c *gin.Context) (uuid.UUID, error) {
 	cookieName := util.GetConfig().Auth.CookieName
 	userID, exists := c.Get(cookieName)
@@ -186,3 +358,23 @@ func GetUserID(c *gin.Context) (uuid.UUID, error) {
 	}
 	return userID.(uuid.UUID), nil
 }
+
+func GetSessionID(c *gin.Context) (uuid.UUID, error) {
+	sessionID, exists := c.Get(sessionKey)
+	if !exists {
+		return uuid.Nil, errors.New("session ID not found")
+	}
+	return sessionID.(uuid.UUID), nil
+}
+
+// GetRole returns the role of a session caller. Requests authenticated by an
+// API key have no role.
+func GetRole(c *gin.Context) (model.Role, bool) {
+	role, exists := c.Get(roleKey)
+	if !exists {
+		return "", false
+	}
+	r, ok := role.(model.Role)
+
+	return r, ok
+}
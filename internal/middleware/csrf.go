@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+const (
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+
+	csrfTokenBytes = 32
+)
+
+// CSRFMiddleware protects cookie-authenticated requests against cross-site
+// request forgery with a double-submit token: state-changing requests that
+// carry an auth cookie must repeat the value of the CSRF cookie in the
+// X-CSRF-Token header. Requests with a Bearer token or an API key can't be
+// forged by a browser and are exempt.
+func CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isSafeMethod(c.Request.Method) || !hasAuthCookie(c) {
+			c.Next()
+			return
+		}
+
+		cookie, err := c.Cookie(CSRFCookie)
+		header := c.GetHeader(CSRFHeader)
+		if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CSRFToken returns the token of the CSRF cookie, issuing a new one if the
+// client has none.
+func CSRFToken(c *gin.Context) (string, error) {
+	if token, err := c.Cookie(CSRFCookie); err == nil && token != "" {
+		return token, nil
+	}
+
+	return setCSRFCookie(c, int(util.GetConfig().Auth.RefreshTokenTTL))
+}
+
+// setCSRFCookie issues a new CSRF token. Unlike the auth cookies it is
+// readable by scripts, which send it back in the X-CSRF-Token header.
+func setCSRFCookie(c *gin.Context, maxAge int) (string, error) {
+	buf := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.WithMessage(err, "failed to generate CSRF token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	writeCookie(c, CSRFCookie, token, maxAge, accessCookieURI, false)
+
+	return token, nil
+}
+
+// hasAuthCookie reports whether the request would be authenticated by a
+// cookie the browser attaches on its own.
+func hasAuthCookie(c *gin.Context) bool {
+	if c.GetHeader(APIKeyHeader) != "" {
+		return false
+	}
+	if _, ok := BearerToken(c); ok {
+		return false
+	}
+
+	cfg := util.GetConfig().Auth
+	for _, name := range []string{cfg.CookieName, cfg.RefreshCookieName} {
+		if value, err := c.Cookie(name); err == nil && value != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
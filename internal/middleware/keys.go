@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+type signingKey struct {
+	util.SigningKey
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// JWK is the public part of an asymmetric signing key as published in the
+// JWKS document.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// keyRing holds the token keyset. It is initialized from the config on
+// first use and may be replaced at runtime with SetSigningKeys.
+type keyRing struct {
+	once   sync.Once
+	mu     sync.RWMutex
+	keys   []signingKey
+	legacy *signingKey
+}
+
+var signingKeys = &keyRing{}
+
+// SetSigningKeys parses the keyset and replaces the current one without
+// restarting the service. The current keyset is kept if any key is invalid.
+func SetSigningKeys(keys []util.SigningKey) error {
+	signingKeys.init()
+
+	parsed, err := parseSigningKeys(keys)
+	if err != nil {
+		return err
+	}
+
+	signingKeys.mu.Lock()
+	defer signingKeys.mu.Unlock()
+
+	signingKeys.keys = parsed
+	util.GetLogger().Infof("token keyset loaded, %d keys", len(parsed))
+
+	return nil
+}
+
+// PublicJWKS returns the public keys of all asymmetric keys that are not
+// retired, including the ones scheduled for future activation.
+func PublicJWKS() interface{} {
+	return signingKeys.jwks(time.Now())
+}
+
+func (r *keyRing) init() {
+	r.once.Do(func() {
+		cfg := util.GetConfig().Auth
+		if cfg.SecretKey != "" {
+			r.legacy = newHMACKey(util.SigningKey{Secret: cfg.SecretKey, RetireAt: cfg.SecretKeyRetireAt})
+		}
+
+		keys, err := parseSigningKeys(cfg.Keys)
+		if err != nil {
+			util.GetLogger().Errorf("failed to load token keyset: %v", err)
+			return
+		}
+		r.keys = keys
+	})
+}
+
+// signing returns the key used for new tokens. A key with an empty ID is the
+// legacy SecretKey.
+func (r *keyRing) signing(now time.Time) (*signingKey, error) {
+	r.init()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if active := r.active(now); active != nil {
+		return active, nil
+	}
+	if r.legacy != nil {
+		return r.legacy, nil
+	}
+
+	return nil, errors.New("no active signing key")
+}
+
+// active returns the newest key of the keyset that is active at now.
+func (r *keyRing) active(now time.Time) *signingKey {
+	var active *signingKey
+	for i := range r.keys {
+		key := &r.keys[i]
+		if key.ActiveFrom.After(now) || isRetired(key, now) {
+			continue
+		}
+		if active == nil || key.ActiveFrom.After(active.ActiveFrom) {
+			active = key
+		}
+	}
+
+	return active
+}
+
+// verification returns the key with the given id, which may be no longer
+// active but still within its grace period. Tokens without an id are
+// verified with the legacy SecretKey while it still signs tokens, and after
+// that only until its RetireAt.
+func (r *keyRing) verification(kid string, now time.Time) (*signingKey, error) {
+	r.init()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if kid == "" {
+		if r.legacy == nil {
+			return nil, errors.New("token without key id")
+		}
+		if r.active(now) != nil && (r.legacy.RetireAt.IsZero() || isRetired(r.legacy, now)) {
+			return nil, errors.New("tokens without key id are no longer accepted")
+		}
+		return r.legacy, nil
+	}
+
+	for i := range r.keys {
+		key := &r.keys[i]
+		if key.ID != kid {
+			continue
+		}
+		if isRetired(key, now) {
+			return nil, errors.Errorf("key %q is retired", kid)
+		}
+		return key, nil
+	}
+
+	return nil, errors.Errorf("unknown key %q", kid)
+}
+
+func (r *keyRing) jwks(now time.Time) JWKSet {
+	r.init()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0)}
+	for i := range r.keys {
+		key := &r.keys[i]
+		if isRetired(key, now) {
+			continue
+		}
+
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	return set
+}
+
+func parseSigningKeys(keys []util.SigningKey) ([]signingKey, error) {
+	parsed := make([]signingKey, 0, len(keys))
+	for _, key := range keys {
+		switch key.Algorithm {
+		case "", util.SigningAlgorithmHS256:
+			parsed = append(parsed, *newHMACKey(key))
+		case util.SigningAlgorithmRS256, util.SigningAlgorithmEdDSA:
+			k, err := loadAsymmetricKey(key)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "error occurred while loading key %q", key.ID)
+			}
+			parsed = append(parsed, *k)
+		default:
+			return nil, errors.Errorf("unsupported algorithm %q of key %q", key.Algorithm, key.ID)
+		}
+	}
+
+	return parsed, nil
+}
+
+func newHMACKey(key util.SigningKey) *signingKey {
+	return &signingKey{
+		SigningKey: key,
+		method:     jwt.SigningMethodHS256,
+		signKey:    []byte(key.Secret),
+		verifyKey:  []byte(key.Secret),
+	}
+}
+
+func loadAsymmetricKey(key util.SigningKey) (*signingKey, error) {
+	data, err := os.ReadFile(key.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Algorithm == util.SigningAlgorithmRS256 {
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return &signingKey{
+			SigningKey: key,
+			method:     jwt.SigningMethodRS256,
+			signKey:    priv,
+			verifyKey:  &priv.PublicKey,
+		}, nil
+	}
+
+	priv, err := jwt.ParseEdPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("EdDSA key is not a signer")
+	}
+	return &signingKey{
+		SigningKey: key,
+		method:     jwt.SigningMethodEdDSA,
+		signKey:    priv,
+		verifyKey:  signer.Public(),
+	}, nil
+}
+
+func isRetired(key *signingKey, now time.Time) bool {
+	return !key.RetireAt.IsZero() && !now.Before(key.RetireAt)
+}
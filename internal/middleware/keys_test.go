@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+func newTestKeyRing(t *testing.T, legacy string, keys ...util.SigningKey) *keyRing {
+	parsed, err := parseSigningKeys(keys)
+	require.NoError(t, err)
+
+	r := &keyRing{keys: parsed}
+	if legacy != "" {
+		r.legacy = newHMACKey(util.SigningKey{Secret: legacy})
+	}
+	r.once.Do(func() {})
+
+	return r
+}
+
+func writePEM(t *testing.T, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	r := newTestKeyRing(t, "legacy",
+		util.SigningKey{ID: "old", Secret: "old-secret", ActiveFrom: now.Add(-48 * time.Hour), RetireAt: now.Add(time.Hour)},
+		util.SigningKey{ID: "current", Secret: "current-secret", ActiveFrom: now.Add(-time.Hour)},
+		util.SigningKey{ID: "next", Secret: "next-secret", ActiveFrom: now.Add(time.Hour)},
+	)
+
+	key, err := r.signing(now)
+	require.NoError(t, err)
+	assert.Equal(t, "current", key.ID)
+	assert.Equal(t, []byte("current-secret"), key.signKey)
+
+	key, err = r.signing(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "next", key.ID, "scheduled key is promoted once active")
+
+	key, err = r.verification("old", now)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old-secret"), key.verifyKey, "old key verifies during grace period")
+
+	_, err = r.verification("old", now.Add(time.Hour))
+	assert.Error(t, err, "old key is rejected after retirement")
+
+	_, err = r.verification("unknown", now)
+	assert.Error(t, err)
+
+	_, err = r.verification("", now)
+	assert.Error(t, err, "tokens without kid are rejected once a key is active")
+}
+
+func TestKeyRingLegacyGracePeriod(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	r := newTestKeyRing(t, "legacy",
+		util.SigningKey{ID: "current", Secret: "current-secret", ActiveFrom: now.Add(time.Hour)},
+	)
+	r.legacy.RetireAt = now.Add(2 * time.Hour)
+
+	key, err := r.verification("", now)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("legacy"), key.verifyKey, "legacy secret still signs before the first key is active")
+
+	key, err = r.verification("", now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("legacy"), key.verifyKey, "tokens without kid are accepted during the grace period")
+
+	_, err = r.verification("", now.Add(2*time.Hour))
+	assert.Error(t, err, "tokens without kid are rejected after SecretKeyRetireAt")
+}
+
+func TestKeyRingLegacyOnly(t *testing.T) {
+	r := newTestKeyRing(t, "legacy")
+
+	key, err := r.signing(time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, key.ID)
+	assert.Equal(t, []byte("legacy"), key.signKey)
+
+	_, err = newTestKeyRing(t, "").signing(time.Now())
+	assert.Error(t, err)
+}
+
+func TestKeyRingAsymmetric(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaDER, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+
+	now := time.Now()
+	r := newTestKeyRing(t, "",
+		util.SigningKey{ID: "rsa", Algorithm: util.SigningAlgorithmRS256, PrivateKeyFile: writePEM(t, rsaDER), ActiveFrom: now.Add(-time.Hour)},
+		util.SigningKey{ID: "ed", Algorithm: util.SigningAlgorithmEdDSA, PrivateKeyFile: writePEM(t, edDER), ActiveFrom: now.Add(time.Hour)},
+		util.SigningKey{ID: "hmac", Secret: "secret", ActiveFrom: now.Add(-2 * time.Hour)},
+	)
+
+	for _, kid := range []string{"rsa", "ed"} {
+		key, err := r.verification(kid, now)
+		require.NoError(t, err)
+
+		signed, err := jwt.NewWithClaims(key.method, jwt.RegisteredClaims{Subject: kid}).SignedString(key.signKey)
+		require.NoError(t, err)
+
+		_, err = jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return key.verifyKey, nil })
+		assert.NoError(t, err, kid)
+	}
+
+	set := r.jwks(now)
+	require.Len(t, set.Keys, 2, "only asymmetric keys are published")
+	assert.Equal(t, JWK{KeyType: "RSA", KeyID: "rsa", Use: "sig", Algorithm: "RS256", N: set.Keys[0].N, E: "AQAB"}, set.Keys[0])
+	assert.Equal(t, "OKP", set.Keys[1].KeyType)
+	assert.Equal(t, "Ed25519", set.Keys[1].Curve)
+	assert.Equal(t, "EdDSA", set.Keys[1].Algorithm)
+
+	_, err = parseSigningKeys([]util.SigningKey{{ID: "bad", Algorithm: util.SigningAlgorithmRS256, PrivateKeyFile: writePEM(t, edDER)}})
+	assert.Error(t, err, "key type must match the algorithm")
+}
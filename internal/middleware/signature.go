@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AccrualSignatureHeader = "X-Accrual-Signature"
+
+	signaturePrefix = "sha256="
+	maxCallbackBody = 1 << 20
+)
+
+// AccrualSignature signs a callback body the way the accrual system does:
+// "sha256=" followed by the hex HMAC-SHA256 of the body with the shared
+// secret.
+func AccrualSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// AccrualSignatureMiddleware rejects callbacks whose X-Accrual-Signature
+// doesn't match the body. The body is buffered and handed on to the handler.
+func AccrualSignatureMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCallbackBody+1))
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxCallbackBody {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		signature := strings.TrimSpace(c.GetHeader(AccrualSignatureHeader))
+		if !hmac.Equal([]byte(signature), []byte(AccrualSignature(secret, body))) {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
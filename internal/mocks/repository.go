@@ -2,8 +2,11 @@ package mocks
 
 import (
 	"context"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
 	"github.com/ypxd99/yandex-diplom-56/internal/repository"
 )
 
@@ -19,4 +22,274 @@ func (m *MockGophermartRepo) Status(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
+func (m *MockGophermartRepo) CreateUser(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetUser(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockGophermartRepo) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
+	args := m.Called(ctx, login)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockGophermartRepo) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) (int, error) {
+	args := m.Called(ctx, userID, passwordHash)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockGophermartRepo) UpdateUserRole(ctx context.Context, userID uuid.UUID, role model.Role) error {
+	args := m.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	args := m.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codes []model.RecoveryCode) error {
+	args := m.Called(ctx, userID, step, codes)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	args := m.Called(ctx, userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockGophermartRepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockGophermartRepo) AcquireLoginAttempt(ctx context.Context, key string, policy model.LockoutPolicy) (*model.LoginAttempt, error) {
+	args := m.Called(ctx, key, policy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LoginAttempt), args.Error(1)
+}
+
+func (m *MockGophermartRepo) ReleaseLoginAttempt(ctx context.Context, attempt *model.LoginAttempt) error {
+	args := m.Called(ctx, attempt)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) ResetLoginAttempts(ctx context.Context, key string, event *model.AuthEvent) error {
+	args := m.Called(ctx, key, event)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) PruneLoginAttempts(ctx context.Context, window time.Duration) (int64, error) {
+	args := m.Called(ctx, window)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockGophermartRepo) AddAuthEvent(ctx context.Context, event *model.AuthEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetAuthEvents(ctx context.Context, login string, limit int) ([]model.AuthEvent, error) {
+	args := m.Called(ctx, login, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AuthEvent), args.Error(1)
+}
+
+func (m *MockGophermartRepo) CreateSession(ctx context.Context, session *model.Session, token *model.RefreshToken) error {
+	args := m.Called(ctx, session, token)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetSession(ctx context.Context, id uuid.UUID) (*model.Session, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Session), args.Error(1)
+}
+
+func (m *MockGophermartRepo) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Session), args.Error(1)
+}
+
+func (m *MockGophermartRepo) TouchSession(ctx context.Context, id uuid.UUID, meta model.SessionMeta) error {
+	args := m.Called(ctx, id, meta)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) RevokeSession(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) RevokeUserSession(ctx context.Context, userID, id uuid.UUID) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) RotateRefreshToken(ctx context.Context, tokenHash string, next *model.RefreshToken) error {
+	args := m.Called(ctx, tokenHash, next)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	args := m.Called(ctx, prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIKey), args.Error(1)
+}
+
+func (m *MockGophermartRepo) GetMerchantAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]model.APIKey, error) {
+	args := m.Called(ctx, merchantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+
+func (m *MockGophermartRepo) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) RevokeAPIKey(ctx context.Context, merchantID, id uuid.UUID) error {
+	args := m.Called(ctx, merchantID, id)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) LinkMerchant(ctx context.Context, merchantID, customerID uuid.UUID) error {
+	args := m.Called(ctx, merchantID, customerID)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) UnlinkMerchant(ctx context.Context, merchantID, customerID uuid.UUID) error {
+	args := m.Called(ctx, merchantID, customerID)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetCustomerMerchants(ctx context.Context, customerID uuid.UUID) ([]model.MerchantCustomer, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.MerchantCustomer), args.Error(1)
+}
+
+func (m *MockGophermartRepo) IsMerchantCustomer(ctx context.Context, merchantID, customerID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, merchantID, customerID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockGophermartRepo) CreateOrder(ctx context.Context, order *model.Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetOrder(ctx context.Context, number string) (*model.Order, error) {
+	args := m.Called(ctx, number)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Order), args.Error(1)
+}
+
+func (m *MockGophermartRepo) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.Order, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Order), args.Error(1)
+}
+
+func (m *MockGophermartRepo) UpdateOrderStatus(ctx context.Context, number string, status model.OrderStatus) error {
+	args := m.Called(ctx, number, status)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) CompleteOrder(ctx context.Context, number string, accrual float64) error {
+	args := m.Called(ctx, number, accrual)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) LeaseAccrualJobs(ctx context.Context, owner string, limit int, visibility time.Duration) ([]model.AccrualJob, error) {
+	args := m.Called(ctx, owner, limit, visibility)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AccrualJob), args.Error(1)
+}
+
+func (m *MockGophermartRepo) RetryAccrualJob(ctx context.Context, job *model.AccrualJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) FlagAccrualJob(ctx context.Context, job *model.AccrualJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetFlaggedAccrualJobs(ctx context.Context, limit int) ([]model.AccrualJob, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AccrualJob), args.Error(1)
+}
+
+func (m *MockGophermartRepo) DeleteAccrualJob(ctx context.Context, number, owner string) error {
+	args := m.Called(ctx, number, owner)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) FinishAccrualJob(ctx context.Context, number string) error {
+	args := m.Called(ctx, number)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetBalance(ctx context.Context, userID uuid.UUID) (*model.Balance, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Balance), args.Error(1)
+}
+
+func (m *MockGophermartRepo) Withdraw(ctx context.Context, withdrawal *model.Withdrawal) error {
+	args := m.Called(ctx, withdrawal)
+	return args.Error(0)
+}
+
+func (m *MockGophermartRepo) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.Withdrawal, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Withdrawal), args.Error(1)
+}
+
 var _ repository.GophermartRepo = (*MockGophermartRepo)(nil)
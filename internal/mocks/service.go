@@ -1,7 +1,11 @@
 package mocks
 
 import (
+	"context"
+
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
 	"github.com/ypxd99/yandex-diplom-56/internal/service"
 )
 
@@ -9,4 +13,199 @@ type MockGophermartService struct {
 	mock.Mock
 }
 
+func (m *MockGophermartService) Register(ctx context.Context, login, password string) (uuid.UUID, error) {
+	args := m.Called(ctx, login, password)
+	return args.Get(0).(uuid.UUID), args.Error(1)
+}
+
+func (m *MockGophermartService) Login(ctx context.Context, login, password, ip string) (uuid.UUID, error) {
+	args := m.Called(ctx, login, password, ip)
+	return args.Get(0).(uuid.UUID), args.Error(1)
+}
+
+func (m *MockGophermartService) ChangePassword(ctx context.Context, userID uuid.UUID, req model.ChangePasswordRequest) error {
+	args := m.Called(ctx, userID, req)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) EnrollTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPEnrollment, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TOTPEnrollment), args.Error(1)
+}
+
+func (m *MockGophermartService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) (*model.RecoveryCodes, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RecoveryCodes), args.Error(1)
+}
+
+func (m *MockGophermartService) VerifySecondFactor(ctx context.Context, userID uuid.UUID, req model.TwoFactorLoginRequest, ip string) error {
+	args := m.Called(ctx, userID, req, ip)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
+	args := m.Called(ctx, login)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockGophermartService) SetUserRole(ctx context.Context, login string, role model.Role) error {
+	args := m.Called(ctx, login, role)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) GetAuthEvents(ctx context.Context, login string) ([]model.AuthEvent, error) {
+	args := m.Called(ctx, login)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AuthEvent), args.Error(1)
+}
+
+func (m *MockGophermartService) PromoteAdmins(ctx context.Context, logins []string) error {
+	args := m.Called(ctx, logins)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) PruneLoginAttempts(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) StartSession(ctx context.Context, userID uuid.UUID, meta model.SessionMeta) (*model.IssuedSession, error) {
+	args := m.Called(ctx, userID, meta)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IssuedSession), args.Error(1)
+}
+
+func (m *MockGophermartService) RefreshSession(ctx context.Context, refreshToken string, meta model.SessionMeta) (*model.IssuedSession, error) {
+	args := m.Called(ctx, refreshToken, meta)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IssuedSession), args.Error(1)
+}
+
+func (m *MockGophermartService) GetSessions(ctx context.Context, userID, currentID uuid.UUID) ([]model.Session, error) {
+	args := m.Called(ctx, userID, currentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Session), args.Error(1)
+}
+
+func (m *MockGophermartService) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) IsSessionActive(ctx context.Context, userID, sessionID uuid.UUID, tokenVersion int) (bool, error) {
+	args := m.Called(ctx, userID, sessionID, tokenVersion)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockGophermartService) CreateAPIKey(ctx context.Context, merchantID uuid.UUID, req model.APIKeyRequest) (*model.IssuedAPIKey, error) {
+	args := m.Called(ctx, merchantID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IssuedAPIKey), args.Error(1)
+}
+
+func (m *MockGophermartService) GetAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]model.APIKey, error) {
+	args := m.Called(ctx, merchantID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+
+func (m *MockGophermartService) RevokeAPIKey(ctx context.Context, merchantID, id uuid.UUID) error {
+	args := m.Called(ctx, merchantID, id)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) LinkMerchant(ctx context.Context, customerID uuid.UUID, merchantLogin string) error {
+	args := m.Called(ctx, customerID, merchantLogin)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) UnlinkMerchant(ctx context.Context, customerID uuid.UUID, merchantLogin string) error {
+	args := m.Called(ctx, customerID, merchantLogin)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) GetLinkedMerchants(ctx context.Context, customerID uuid.UUID) ([]model.LinkedMerchant, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.LinkedMerchant), args.Error(1)
+}
+
+func (m *MockGophermartService) AuthenticateAPIKey(ctx context.Context, rawKey, customerLogin string) (*model.APIKey, uuid.UUID, error) {
+	args := m.Called(ctx, rawKey, customerLogin)
+	if args.Get(0) == nil {
+		return nil, uuid.Nil, args.Error(2)
+	}
+	return args.Get(0).(*model.APIKey), args.Get(1).(uuid.UUID), args.Error(2)
+}
+
+func (m *MockGophermartService) UploadOrder(ctx context.Context, userID uuid.UUID, number string) error {
+	args := m.Called(ctx, userID, number)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.Order, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Order), args.Error(1)
+}
+
+func (m *MockGophermartService) GetOrdersForReview(ctx context.Context) ([]model.AccrualJob, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AccrualJob), args.Error(1)
+}
+
+func (m *MockGophermartService) ApplyAccrual(ctx context.Context, update model.AccrualOrder) error {
+	args := m.Called(ctx, update)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) GetBalance(ctx context.Context, userID uuid.UUID) (*model.Balance, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Balance), args.Error(1)
+}
+
+func (m *MockGophermartService) Withdraw(ctx context.Context, userID uuid.UUID, req model.WithdrawRequest) error {
+	args := m.Called(ctx, userID, req)
+	return args.Error(0)
+}
+
+func (m *MockGophermartService) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.Withdrawal, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Withdrawal), args.Error(1)
+}
+
 var _ service.GophermartService = (*MockGophermartService)(nil)
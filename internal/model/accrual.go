@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type AccrualStatus string
+
+const (
+	AccrualStatusRegistered AccrualStatus = "REGISTERED"
+	AccrualStatusProcessing AccrualStatus = "PROCESSING"
+	AccrualStatusInvalid    AccrualStatus = "INVALID"
+	AccrualStatusProcessed  AccrualStatus = "PROCESSED"
+)
+
+// AccrualOrder is the accrual system's view of an order.
+type AccrualOrder struct {
+	Order   string        `json:"order"`
+	Status  AccrualStatus `json:"status"`
+	Accrual *float64      `json:"accrual,omitempty"`
+}
+
+// OrderStatus maps an accrual system status onto the order status exposed
+// to users.
+func (s AccrualStatus) OrderStatus() (OrderStatus, bool) {
+	switch s {
+	case AccrualStatusRegistered, AccrualStatusProcessing:
+		return OrderStatusProcessing, true
+	case AccrualStatusInvalid:
+		return OrderStatusInvalid, true
+	case AccrualStatusProcessed:
+		return OrderStatusProcessed, true
+	default:
+		return "", false
+	}
+}
+
+// AccrualJob is a queued poll of the accrual system for a pending order. A
+// worker leases the job until LeasedUntil; if the worker dies, the job
+// becomes visible to other workers once the lease runs out. Attempts counts
+// the polls since the order status last changed and drives the backoff to
+// NextAttemptAt. Orders pending for too long get ReviewAt set and are no
+// longer polled.
+type AccrualJob struct {
+	bun.BaseModel `bun:"table:accrual_jobs,alias:aj"`
+
+	OrderNumber   string     `bun:"order_number,pk" json:"order_number"`
+	Attempts      int        `bun:"attempts,notnull,default:0" json:"attempts"`
+	NextAttemptAt time.Time  `bun:"next_attempt_at,notnull,default:current_timestamp" json:"next_attempt_at"`
+	LeasedUntil   *time.Time `bun:"leased_until" json:"-"`
+	LeaseOwner    string     `bun:"lease_owner,nullzero" json:"-"`
+	LastError     string     `bun:"last_error,nullzero" json:"last_error,omitempty"`
+	ReviewAt      *time.Time `bun:"review_at" json:"review_at,omitempty"`
+	CreatedAt     time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+
+	Order *Order `bun:"rel:belongs-to,join:order_number=number" json:"order,omitempty"`
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Scopes an API key may be granted. Requests authenticated by an API key can
+// only reach the routes guarded by one of the key's scopes.
+const (
+	ScopeOrdersWrite     = "orders:write"
+	ScopeOrdersRead      = "orders:read"
+	ScopeBalanceRead     = "balance:read"
+	ScopeWithdrawalsRead = "withdrawals:read"
+)
+
+var KnownScopes = []string{ScopeOrdersWrite, ScopeOrdersRead, ScopeBalanceRead, ScopeWithdrawalsRead}
+
+// APIKey lets a merchant backend act on behalf of its customers without a
+// browser session. The merchant is the account that created the key, and it
+// can only act for the customers that linked it. The
+// key is handed out as gm_<prefix>_<secret>; only the SHA-256 hash of the
+// secret is stored and the prefix is used to look the key up.
+type APIKey struct {
+	bun.BaseModel `bun:"table:api_keys,alias:ak"`
+
+	ID         uuid.UUID  `bun:"id,pk,type:uuid" json:"id"`
+	MerchantID uuid.UUID  `bun:"merchant_id,type:uuid,notnull" json:"-"`
+	Name       string     `bun:"name,notnull" json:"name"`
+	Prefix     string     `bun:"prefix,notnull,unique" json:"prefix"`
+	SecretHash string     `bun:"secret_hash,notnull" json:"-"`
+	Scopes     []string   `bun:"scopes,array,notnull" json:"scopes"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	LastUsedAt *time.Time `bun:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `bun:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// IssuedAPIKey is returned once on creation; the full key can't be
+// recovered later.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// MerchantCustomer is the consent of a customer for the merchant to act on
+// its behalf with the merchant's API keys.
+type MerchantCustomer struct {
+	bun.BaseModel `bun:"table:merchant_customers,alias:mc"`
+
+	MerchantID uuid.UUID `bun:"merchant_id,pk,type:uuid"`
+	CustomerID uuid.UUID `bun:"customer_id,pk,type:uuid"`
+	Merchant   *User     `bun:"rel:belongs-to,join:merchant_id=id"`
+	CreatedAt  time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+type MerchantRequest struct {
+	Merchant string `json:"merchant"`
+}
+
+// LinkedMerchant is a merchant the customer has linked.
+type LinkedMerchant struct {
+	Merchant string    `json:"merchant"`
+	LinkedAt time.Time `json:"linked_at"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type LedgerOperation string
+
+const (
+	LedgerOperationAccrual    LedgerOperation = "ACCRUAL"
+	LedgerOperationWithdrawal LedgerOperation = "WITHDRAWAL"
+)
+
+type Balance struct {
+	bun.BaseModel `bun:"table:balances,alias:b"`
+
+	UserID    uuid.UUID `bun:"user_id,pk,type:uuid" json:"-"`
+	Current   float64   `bun:"current,notnull" json:"current"`
+	Withdrawn float64   `bun:"withdrawn,notnull" json:"withdrawn"`
+}
+
+// LedgerEntry is an append-only record of a single balance movement. Amount
+// is positive for accruals and negative for withdrawals.
+type LedgerEntry struct {
+	bun.BaseModel `bun:"table:ledger,alias:l"`
+
+	ID          int64           `bun:"id,pk,autoincrement"`
+	UserID      uuid.UUID       `bun:"user_id,type:uuid,notnull"`
+	OrderNumber string          `bun:"order_number,notnull"`
+	Operation   LedgerOperation `bun:"operation,notnull"`
+	Amount      float64         `bun:"amount,notnull"`
+	CreatedAt   time.Time       `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "github.com/pkg/errors"
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrLoginTaken         = errors.New("login already taken")
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrInvalidAuthRequest = errors.New("login and password must not be empty")
+	ErrLoginLocked        = errors.New("too many failed login attempts")
+	ErrWrongPassword      = errors.New("current password is wrong")
+	ErrPasswordTooShort   = errors.New("password is too short")
+	ErrPasswordTooLong    = errors.New("password is too long")
+	ErrPasswordBreached   = errors.New("password is too common, choose another one")
+	ErrInvalidRole        = errors.New("unknown role")
+
+	ErrTwoFactorRequired   = errors.New("second authentication factor required")
+	ErrInvalidTOTPCode     = errors.New("invalid one-time code")
+	ErrTOTPAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnrolled     = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidPreAuthToken = errors.New("invalid pre-auth token")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+
+	ErrInvalidAPIKey        = errors.New("invalid API key")
+	ErrInvalidAPIKeyRequest = errors.New("API key name and at least one known scope are required")
+	ErrNotMerchant          = errors.New("account is not a merchant")
+	ErrCustomerNotLinked    = errors.New("customer has not linked the merchant")
+
+	ErrOrderExists            = errors.New("order already exists")
+	ErrOrderAlreadyUploaded   = errors.New("order already uploaded by this user")
+	ErrOrderUploadedByAnother = errors.New("order already uploaded by another user")
+	ErrInvalidOrderNumber     = errors.New("invalid order number")
+	ErrInvalidAccrualUpdate   = errors.New("accrual update must have an order, a known status and a non-negative accrual")
+
+	ErrInsufficientFunds  = errors.New("insufficient funds")
+	ErrInvalidWithdrawSum = errors.New("withdrawal sum must be positive")
+	ErrWithdrawSumScale   = errors.New("withdrawal sum must not have more than two decimal places")
+	ErrWithdrawalExists   = errors.New("withdrawal for this order already exists")
+)
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+type AuthEventType string
+
+const (
+	AuthEventLockout AuthEventType = "LOCKOUT"
+	AuthEventUnlock  AuthEventType = "UNLOCK"
+)
+
+// LoginAttempt counts recent failed logins for a key, which is either a login
+// or a client IP.
+type LoginAttempt struct {
+	bun.BaseModel `bun:"table:login_attempts,alias:la"`
+
+	Key         string     `bun:"key,pk"`
+	Failures    int        `bun:"failures,notnull"`
+	LockedUntil *time.Time `bun:"locked_until"`
+	UpdatedAt   time.Time  `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+// LockoutPolicy limits the failed logins of a key. The key is locked once its
+// failures reach Threshold, for BaseDelay doubled for every failure past it
+// and capped at MaxDelay. Failures are forgotten after Window.
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// MaxLockoutDoublings bounds the exponent of the lockout delay.
+const MaxLockoutDoublings = 30
+
+// Delay returns how long the key is locked after the given number of
+// failures.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < failures-p.Threshold && i < MaxLockoutDoublings && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+// Locks reports whether the attempt counted with the given failures locks
+// the key.
+func (p LockoutPolicy) Locks(failures int) bool {
+	return failures >= p.Threshold
+}
+
+// AuthEvent records a lockout or an unlock, so support can tell why a
+// customer can't sign in.
+type AuthEvent struct {
+	bun.BaseModel `bun:"table:auth_events,alias:ae"`
+
+	ID          int64         `bun:"id,pk,autoincrement" json:"-"`
+	Key         string        `bun:"key,notnull" json:"key"`
+	Login       string        `bun:"login,notnull" json:"login"`
+	IP          string        `bun:"ip,notnull" json:"ip"`
+	Event       AuthEventType `bun:"event,notnull" json:"event"`
+	Failures    int           `bun:"failures,notnull" json:"failures"`
+	LockedUntil *time.Time    `bun:"locked_until" json:"locked_until,omitempty"`
+	CreatedAt   time.Time     `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// LoginLockedError is returned while a login or an IP is locked out.
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return ErrLoginLocked.Error()
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrLoginLocked
+}
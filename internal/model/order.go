@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusNew        OrderStatus = "NEW"
+	OrderStatusProcessing OrderStatus = "PROCESSING"
+	OrderStatusInvalid    OrderStatus = "INVALID"
+	OrderStatusProcessed  OrderStatus = "PROCESSED"
+)
+
+type Order struct {
+	bun.BaseModel `bun:"table:orders,alias:o"`
+
+	Number     string      `bun:"number,pk" json:"number"`
+	UserID     uuid.UUID   `bun:"user_id,type:uuid,notnull" json:"-"`
+	Status     OrderStatus `bun:"status,notnull" json:"status"`
+	Accrual    *float64    `bun:"accrual" json:"accrual,omitempty"`
+	UploadedAt time.Time   `bun:"uploaded_at,notnull,default:current_timestamp" json:"uploaded_at"`
+}
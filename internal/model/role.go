@@ -0,0 +1,23 @@
+package model
+
+type Role string
+
+const (
+	RoleUser     Role = "user"
+	RoleMerchant Role = "merchant"
+	RoleSupport  Role = "support"
+	RoleAdmin    Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleUser, RoleMerchant, RoleSupport, RoleAdmin:
+		return true
+	}
+
+	return false
+}
+
+type RoleRequest struct {
+	Role Role `json:"role"`
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Session is a family of refresh tokens issued from a single login. Revoking
+// it invalidates every refresh token of the family and every access token
+// issued for it.
+type Session struct {
+	bun.BaseModel `bun:"table:sessions,alias:s"`
+
+	ID         uuid.UUID  `bun:"id,pk,type:uuid" json:"id"`
+	UserID     uuid.UUID  `bun:"user_id,type:uuid,notnull" json:"-"`
+	Device     string     `bun:"device,notnull,default:''" json:"device,omitempty"`
+	UserAgent  string     `bun:"user_agent,notnull,default:''" json:"user_agent"`
+	IP         string     `bun:"ip,notnull,default:''" json:"ip"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	LastSeenAt time.Time  `bun:"last_seen_at,notnull,default:current_timestamp" json:"last_seen_at"`
+	RevokedAt  *time.Time `bun:"revoked_at" json:"-"`
+	Current    bool       `bun:"-" json:"current"`
+}
+
+// SessionMeta describes the client a session is opened or refreshed from.
+type SessionMeta struct {
+	Device    string
+	UserAgent string
+	IP        string
+}
+
+// RefreshToken is stored only as a SHA-256 hash of the token handed to the
+// client. A token is single use: rotating it sets UsedAt.
+type RefreshToken struct {
+	bun.BaseModel `bun:"table:refresh_tokens,alias:rt"`
+
+	ID        int64      `bun:"id,pk,autoincrement"`
+	SessionID uuid.UUID  `bun:"session_id,type:uuid,notnull"`
+	UserID    uuid.UUID  `bun:"user_id,type:uuid,notnull"`
+	TokenHash string     `bun:"token_hash,notnull,unique"`
+	ExpiresAt time.Time  `bun:"expires_at,notnull"`
+	CreatedAt time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	UsedAt    *time.Time `bun:"used_at"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// IssuedSession is what the client receives after login or refresh. The
+// refresh token is returned in plain text only here.
+type IssuedSession struct {
+	UserID           uuid.UUID
+	SessionID        uuid.UUID
+	Role             Role
+	TokenVersion     int
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// TokenResponse carries the tokens in the response body for clients that
+// can't keep cookies.
+type TokenResponse struct {
+	TokenType        string    `json:"token_type"`
+	AccessToken      string    `json:"access_token"`
+	ExpiresIn        int64     `json:"expires_in"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// CSRFTokenResponse carries the token cookie-authenticated clients send in
+// the X-CSRF-Token header of state-changing requests.
+type CSRFTokenResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
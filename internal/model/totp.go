@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// RecoveryCode is a single use replacement for a TOTP code. Only its
+// SHA-256 hash is stored.
+type RecoveryCode struct {
+	bun.BaseModel `bun:"table:recovery_codes,alias:rc"`
+
+	ID        int64      `bun:"id,pk,autoincrement"`
+	UserID    uuid.UUID  `bun:"user_id,type:uuid,notnull"`
+	CodeHash  string     `bun:"code_hash,notnull"`
+	CreatedAt time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	UsedAt    *time.Time `bun:"used_at"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TOTPRequest struct {
+	Code string `json:"code"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// TwoFactorLoginRequest completes a login with either a TOTP code or a
+// recovery code. The pre-auth token may come in the body, the
+// `Authorization: Bearer` header or its cookie.
+type TwoFactorLoginRequest struct {
+	PreAuthToken string `json:"pre_auth_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// PreAuthResponse is returned by the first login step when the account has
+// 2FA enabled.
+type PreAuthResponse struct {
+	PreAuthToken string `json:"pre_auth_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// User is a registered account. Role and TokenVersion are embedded in access
+// tokens; TokenVersion is bumped on password or role change, which
+// invalidates every token issued before. TOTPSecret is set on 2FA enrollment
+// and takes effect once TOTPEnabled is confirmed with a first code.
+type User struct {
+	bun.BaseModel `bun:"table:users,alias:u"`
+
+	ID           uuid.UUID `bun:"id,pk,type:uuid" json:"id"`
+	Login        string    `bun:"login,notnull,unique" json:"login"`
+	PasswordHash string    `bun:"password_hash,notnull" json:"-"`
+	Role         Role      `bun:"role,notnull,default:'user'" json:"role"`
+	TokenVersion int       `bun:"token_version,notnull,default:0" json:"-"`
+	TOTPSecret   string    `bun:"totp_secret,nullzero" json:"-"`
+	TOTPEnabled  bool      `bun:"totp_enabled,notnull,default:false" json:"totp_enabled"`
+	TOTPLastStep int64     `bun:"totp_last_step,notnull,default:0" json:"-"`
+	CreatedAt    time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
+
+type AuthRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type Withdrawal struct {
+	bun.BaseModel `bun:"table:withdrawals,alias:w"`
+
+	ID          int64     `bun:"id,pk,autoincrement" json:"-"`
+	UserID      uuid.UUID `bun:"user_id,type:uuid,notnull" json:"-"`
+	OrderNumber string    `bun:"order_number,notnull,unique" json:"order"`
+	Sum         float64   `bun:"sum,notnull" json:"sum"`
+	ProcessedAt time.Time `bun:"processed_at,notnull,default:current_timestamp" json:"processed_at"`
+}
+
+// MarshalJSON renders processed_at in RFC3339 with second precision, the
+// format finance reconciles against.
+func (w Withdrawal) MarshalJSON() ([]byte, error) {
+	type alias Withdrawal
+	return json.Marshal(struct {
+		alias
+		ProcessedAt string `json:"processed_at"`
+	}{
+		alias:       alias(w),
+		ProcessedAt: w.ProcessedAt.Format(time.RFC3339),
+	})
+}
+
+type WithdrawRequest struct {
+	Order string  `json:"order"`
+	Sum   float64 `json:"sum"`
+}
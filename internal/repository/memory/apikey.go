@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (m *Memory) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	stored := *key
+	stored.Scopes = append([]string(nil), key.Scopes...)
+	m.apiKeys[key.Prefix] = &stored
+
+	return nil
+}
+
+func (m *Memory) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, exists := m.apiKeys[prefix]
+	if !exists {
+		return nil, model.ErrNotFound
+	}
+	res := *key
+
+	return &res, nil
+}
+
+func (m *Memory) GetMerchantAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]model.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]model.APIKey, 0)
+	for _, key := range m.apiKeys {
+		if key.MerchantID == merchantID {
+			keys = append(keys, *key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (m *Memory) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.apiKeys {
+		if key.ID == id {
+			now := time.Now()
+			key.LastUsedAt = &now
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) RevokeAPIKey(ctx context.Context, merchantID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.apiKeys {
+		if key.ID != id || key.MerchantID != merchantID {
+			continue
+		}
+		if key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+		}
+		return nil
+	}
+
+	return model.ErrNotFound
+}
+
+// LinkMerchant records the customer's consent for the merchant. The links are
+// kept per customer.
+func (m *Memory) LinkMerchant(ctx context.Context, merchantID, customerID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	merchants, exists := m.merchantCustomers[customerID]
+	if !exists {
+		merchants = make(map[uuid.UUID]time.Time)
+		m.merchantCustomers[customerID] = merchants
+	}
+	if _, linked := merchants[merchantID]; !linked {
+		merchants[merchantID] = time.Now()
+	}
+
+	return nil
+}
+
+func (m *Memory) UnlinkMerchant(ctx context.Context, merchantID, customerID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, linked := m.merchantCustomers[customerID][merchantID]; !linked {
+		return model.ErrNotFound
+	}
+	delete(m.merchantCustomers[customerID], merchantID)
+
+	return nil
+}
+
+func (m *Memory) GetCustomerMerchants(ctx context.Context, customerID uuid.UUID) ([]model.MerchantCustomer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	links := make([]model.MerchantCustomer, 0)
+	for merchantID, createdAt := range m.merchantCustomers[customerID] {
+		link := model.MerchantCustomer{MerchantID: merchantID, CustomerID: customerID, CreatedAt: createdAt}
+		if merchant, exists := m.users[merchantID]; exists {
+			res := *merchant
+			link.Merchant = &res
+		}
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+
+	return links, nil
+}
+
+func (m *Memory) IsMerchantCustomer(ctx context.Context, merchantID, customerID uuid.UUID) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, linked := m.merchantCustomers[customerID][merchantID]
+
+	return linked, nil
+}
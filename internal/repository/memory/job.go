@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (m *Memory) LeaseAccrualJobs(ctx context.Context, owner string, limit int, visibility time.Duration) ([]model.AccrualJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	due := make([]*model.AccrualJob, 0)
+	for _, job := range m.accrualJobs {
+		if job.ReviewAt != nil || job.NextAttemptAt.After(now) || (job.LeasedUntil != nil && job.LeasedUntil.After(now)) {
+			continue
+		}
+		due = append(due, job)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	leasedUntil := now.Add(visibility)
+	jobs := make([]model.AccrualJob, 0, len(due))
+	for _, job := range due {
+		job.LeasedUntil = &leasedUntil
+		job.LeaseOwner = owner
+
+		res := *job
+		if order, exists := m.orders[job.OrderNumber]; exists {
+			o := *order
+			res.Order = &o
+		}
+		jobs = append(jobs, res)
+	}
+
+	return jobs, nil
+}
+
+func (m *Memory) RetryAccrualJob(ctx context.Context, job *model.AccrualJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.accrualJobs[job.OrderNumber]
+	if !exists || stored.LeaseOwner != job.LeaseOwner {
+		return nil
+	}
+	stored.Attempts = job.Attempts
+	stored.NextAttemptAt = job.NextAttemptAt
+	stored.LastError = job.LastError
+	stored.LeasedUntil = nil
+	stored.LeaseOwner = ""
+
+	return nil
+}
+
+func (m *Memory) FlagAccrualJob(ctx context.Context, job *model.AccrualJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, exists := m.accrualJobs[job.OrderNumber]
+	if !exists || stored.LeaseOwner != job.LeaseOwner {
+		return nil
+	}
+	now := time.Now()
+	stored.Attempts = job.Attempts
+	stored.LastError = job.LastError
+	stored.ReviewAt = &now
+	stored.LeasedUntil = nil
+	stored.LeaseOwner = ""
+
+	return nil
+}
+
+func (m *Memory) GetFlaggedAccrualJobs(ctx context.Context, limit int) ([]model.AccrualJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := make([]model.AccrualJob, 0)
+	for _, job := range m.accrualJobs {
+		if job.ReviewAt == nil {
+			continue
+		}
+		res := *job
+		if order, exists := m.orders[job.OrderNumber]; exists {
+			o := *order
+			res.Order = &o
+		}
+		jobs = append(jobs, res)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ReviewAt.Before(*jobs[j].ReviewAt)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+
+	return jobs, nil
+}
+
+func (m *Memory) DeleteAccrualJob(ctx context.Context, number, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job, exists := m.accrualJobs[number]; exists && job.LeaseOwner == owner {
+		delete(m.accrualJobs, number)
+	}
+
+	return nil
+}
+
+func (m *Memory) FinishAccrualJob(ctx context.Context, number string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.accrualJobs, number)
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func TestAccrualJobLeasing(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	require.NoError(t, m.CreateOrder(ctx, &model.Order{Number: "1", UserID: uuid.New(), Status: model.OrderStatusNew}))
+
+	first, err := m.LeaseAccrualJobs(ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+	require.NotNil(t, first[0].Order)
+	assert.Equal(t, model.OrderStatusNew, first[0].Order.Status)
+
+	second, err := m.LeaseAccrualJobs(ctx, "b", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, second, "leased job is invisible to other workers")
+
+	job := first[0]
+	job.LeaseOwner = "b"
+	job.NextAttemptAt = time.Now()
+	require.NoError(t, m.RetryAccrualJob(ctx, &job))
+	second, err = m.LeaseAccrualJobs(ctx, "b", 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, second, "only the lease owner can release the job")
+
+	job.LeaseOwner = "a"
+	job.Attempts = 1
+	require.NoError(t, m.RetryAccrualJob(ctx, &job))
+	second, err = m.LeaseAccrualJobs(ctx, "b", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.Equal(t, 1, second[0].Attempts)
+
+	require.NoError(t, m.DeleteAccrualJob(ctx, "1", "a"))
+	assert.Contains(t, m.accrualJobs, "1", "only the lease owner can delete the job")
+	require.NoError(t, m.DeleteAccrualJob(ctx, "1", "b"))
+	assert.NotContains(t, m.accrualJobs, "1")
+}
+
+func TestFlaggedAccrualJob(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	require.NoError(t, m.CreateOrder(ctx, &model.Order{Number: "1", UserID: uuid.New(), Status: model.OrderStatusProcessing}))
+
+	jobs, err := m.LeaseAccrualJobs(ctx, "a", 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	job := jobs[0]
+	job.LastError = "still processing"
+	require.NoError(t, m.FlagAccrualJob(ctx, &job))
+
+	jobs, err = m.LeaseAccrualJobs(ctx, "a", 10, -time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, jobs, "flagged jobs are not polled")
+
+	flagged, err := m.GetFlaggedAccrualJobs(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	assert.NotNil(t, flagged[0].ReviewAt)
+	assert.Equal(t, "still processing", flagged[0].LastError)
+	require.NotNil(t, flagged[0].Order)
+	assert.Equal(t, model.OrderStatusProcessing, flagged[0].Order.Status)
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (m *Memory) AcquireLoginAttempt(ctx context.Context, key string, policy model.LockoutPolicy) (*model.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	attempt, exists := m.loginAttempts[key]
+	if !exists {
+		attempt = &model.LoginAttempt{Key: key}
+		m.loginAttempts[key] = attempt
+	}
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+		return nil, &model.LoginLockedError{Until: *attempt.LockedUntil}
+	}
+
+	if loginAttemptLast(attempt).Before(now.Add(-policy.Window)) {
+		attempt.Failures = 0
+		attempt.LockedUntil = nil
+	}
+	attempt.Failures++
+	attempt.UpdatedAt = now
+	if policy.Locks(attempt.Failures) {
+		until := now.Add(policy.Delay(attempt.Failures))
+		attempt.LockedUntil = &until
+	}
+	res := *attempt
+
+	return &res, nil
+}
+
+func (m *Memory) ReleaseLoginAttempt(ctx context.Context, attempt *model.LoginAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, exists := m.loginAttempts[attempt.Key]
+	if !exists {
+		return nil
+	}
+	if current.Failures > 0 {
+		current.Failures--
+	}
+	if current.LockedUntil != nil && attempt.LockedUntil != nil && current.LockedUntil.Equal(*attempt.LockedUntil) {
+		current.LockedUntil = nil
+	}
+
+	return nil
+}
+
+func (m *Memory) PruneLoginAttempts(ctx context.Context, window time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pruned int64
+	before := time.Now().Add(-window)
+	for key, attempt := range m.loginAttempts {
+		if loginAttemptLast(attempt).Before(before) {
+			delete(m.loginAttempts, key)
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+func (m *Memory) AddAuthEvent(ctx context.Context, event *model.AuthEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.addAuthEvent(event)
+
+	return nil
+}
+
+func (m *Memory) ResetLoginAttempts(ctx context.Context, key string, event *model.AuthEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.loginAttempts, key)
+	if event != nil {
+		m.addAuthEvent(event)
+	}
+
+	return nil
+}
+
+func (m *Memory) GetAuthEvents(ctx context.Context, login string, limit int) ([]model.AuthEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := make([]model.AuthEvent, 0)
+	for i := len(m.authEvents) - 1; i >= 0 && len(events) < limit; i-- {
+		if m.authEvents[i].Login == login {
+			events = append(events, m.authEvents[i])
+		}
+	}
+
+	return events, nil
+}
+
+// addAuthEvent stores the event. The caller must hold the write lock.
+func (m *Memory) addAuthEvent(event *model.AuthEvent) {
+	event.ID = int64(len(m.authEvents) + 1)
+	event.CreatedAt = time.Now()
+	m.authEvents = append(m.authEvents, *event)
+}
+
+// loginAttemptLast returns when the attempt last failed or was locked.
+func loginAttemptLast(attempt *model.LoginAttempt) time.Time {
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(attempt.UpdatedAt) {
+		return *attempt.LockedUntil
+	}
+
+	return attempt.UpdatedAt
+}
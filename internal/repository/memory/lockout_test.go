@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+var testLockoutPolicy = model.LockoutPolicy{
+	Threshold: 3,
+	BaseDelay: time.Minute,
+	MaxDelay:  time.Hour,
+	Window:    time.Hour,
+}
+
+func TestAcquireLoginAttemptIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+
+	var acquired, locked atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.AcquireLoginAttempt(ctx, "login:a", testLockoutPolicy)
+			if err != nil {
+				assert.ErrorIs(t, err, model.ErrLoginLocked)
+				locked.Add(1)
+				return
+			}
+			acquired.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, testLockoutPolicy.Threshold, acquired.Load(), "parallel attempts can't exceed the threshold")
+	assert.EqualValues(t, 20-testLockoutPolicy.Threshold, locked.Load())
+}
+
+func TestReleaseLoginAttempt(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+
+	var attempt *model.LoginAttempt
+	for i := 0; i < testLockoutPolicy.Threshold; i++ {
+		var err error
+		attempt, err = m.AcquireLoginAttempt(ctx, "ip:a", testLockoutPolicy)
+		require.NoError(t, err)
+	}
+	require.NotNil(t, attempt.LockedUntil, "the attempt reaching the threshold locks the key")
+
+	require.NoError(t, m.ReleaseLoginAttempt(ctx, attempt))
+	attempt, err := m.AcquireLoginAttempt(ctx, "ip:a", testLockoutPolicy)
+	require.NoError(t, err, "a released attempt takes back its lock")
+	assert.Equal(t, testLockoutPolicy.Threshold, attempt.Failures)
+}
+
+func TestLoginAttemptLockExpires(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+
+	for i := 0; i < testLockoutPolicy.Threshold; i++ {
+		_, err := m.AcquireLoginAttempt(ctx, "login:a", testLockoutPolicy)
+		require.NoError(t, err)
+	}
+	_, err := m.AcquireLoginAttempt(ctx, "login:a", testLockoutPolicy)
+	var locked *model.LoginLockedError
+	require.ErrorAs(t, err, &locked)
+
+	expired := time.Now().Add(-time.Second)
+	m.loginAttempts["login:a"].LockedUntil = &expired
+	attempt, err := m.AcquireLoginAttempt(ctx, "login:a", testLockoutPolicy)
+	require.NoError(t, err, "unlocked once the lock expires")
+	assert.Equal(t, testLockoutPolicy.Threshold+1, attempt.Failures)
+	require.NotNil(t, attempt.LockedUntil)
+	assert.WithinDuration(t, time.Now().Add(2*testLockoutPolicy.BaseDelay), *attempt.LockedUntil, time.Second,
+		"the delay doubles with each failure past the threshold")
+}
+
+func TestPruneLoginAttempts(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+
+	_, err := m.AcquireLoginAttempt(ctx, "login:old", testLockoutPolicy)
+	require.NoError(t, err)
+	m.loginAttempts["login:old"].UpdatedAt = time.Now().Add(-2 * testLockoutPolicy.Window)
+	_, err = m.AcquireLoginAttempt(ctx, "login:new", testLockoutPolicy)
+	require.NoError(t, err)
+
+	pruned, err := m.PruneLoginAttempts(ctx, testLockoutPolicy.Window)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, pruned)
+	assert.Contains(t, m.loginAttempts, "login:new")
+	assert.NotContains(t, m.loginAttempts, "login:old")
+}
@@ -0,0 +1,314 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/internal/repository"
+)
+
+// Memory is a concurrency-safe in-memory GophermartRepo for running the
+// service without Postgres. All data is lost on restart.
+type Memory struct {
+	mu          sync.RWMutex
+	users       map[uuid.UUID]*model.User
+	logins      map[string]uuid.UUID
+	orders      map[string]*model.Order
+	balances    map[uuid.UUID]*model.Balance
+	withdrawals []model.Withdrawal
+	ledger      []model.LedgerEntry
+
+	sessions      map[uuid.UUID]*model.Session
+	refreshTokens map[string]*model.RefreshToken
+
+	loginAttempts map[string]*model.LoginAttempt
+	authEvents    []model.AuthEvent
+
+	apiKeys           map[string]*model.APIKey
+	merchantCustomers map[uuid.UUID]map[uuid.UUID]time.Time
+
+	recoveryCodes map[uuid.UUID][]model.RecoveryCode
+
+	accrualJobs map[string]*model.AccrualJob
+}
+
+func New() *Memory {
+	return &Memory{
+		users:    make(map[uuid.UUID]*model.User),
+		logins:   make(map[string]uuid.UUID),
+		orders:   make(map[string]*model.Order),
+		balances: make(map[uuid.UUID]*model.Balance),
+
+		sessions:      make(map[uuid.UUID]*model.Session),
+		refreshTokens: make(map[string]*model.RefreshToken),
+
+		loginAttempts: make(map[string]*model.LoginAttempt),
+
+		apiKeys:           make(map[string]*model.APIKey),
+		merchantCustomers: make(map[uuid.UUID]map[uuid.UUID]time.Time),
+
+		recoveryCodes: make(map[uuid.UUID][]model.RecoveryCode),
+
+		accrualJobs: make(map[string]*model.AccrualJob),
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) Status(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, user *model.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.logins[user.Login]; exists {
+		return model.ErrLoginTaken
+	}
+
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	stored := *user
+	m.users[user.ID] = &stored
+	m.logins[user.Login] = user.ID
+
+	return nil
+}
+
+func (m *Memory) GetUser(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, exists := m.users[id]
+	if !exists {
+		return nil, model.ErrNotFound
+	}
+	res := *user
+
+	return &res, nil
+}
+
+func (m *Memory) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, exists := m.logins[login]
+	if !exists {
+		return nil, model.ErrNotFound
+	}
+	user := *m.users[id]
+
+	return &user, nil
+}
+
+func (m *Memory) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, exists := m.users[userID]
+	if !exists {
+		return 0, model.ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	user.TokenVersion++
+
+	for id, session := range m.sessions {
+		if session.UserID == userID {
+			m.revokeSession(id)
+		}
+	}
+
+	return user.TokenVersion, nil
+}
+
+func (m *Memory) UpdateUserRole(ctx context.Context, userID uuid.UUID, role model.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, exists := m.users[userID]
+	if !exists {
+		return model.ErrNotFound
+	}
+	user.Role = role
+	user.TokenVersion++
+
+	return nil
+}
+
+func (m *Memory) CreateOrder(ctx context.Context, order *model.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.orders[order.Number]; exists {
+		return model.ErrOrderExists
+	}
+
+	if order.UploadedAt.IsZero() {
+		order.UploadedAt = time.Now()
+	}
+	stored := *order
+	m.orders[order.Number] = &stored
+	m.accrualJobs[order.Number] = &model.AccrualJob{
+		OrderNumber:   order.Number,
+		NextAttemptAt: order.UploadedAt,
+		CreatedAt:     order.UploadedAt,
+	}
+
+	return nil
+}
+
+func (m *Memory) GetOrder(ctx context.Context, number string) (*model.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, exists := m.orders[number]
+	if !exists {
+		return nil, model.ErrNotFound
+	}
+	res := *order
+
+	return &res, nil
+}
+
+func (m *Memory) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := make([]model.Order, 0)
+	for _, order := range m.orders {
+		if order.UserID == userID {
+			orders = append(orders, *order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].UploadedAt.After(orders[j].UploadedAt)
+	})
+
+	return orders, nil
+}
+
+func (m *Memory) UpdateOrderStatus(ctx context.Context, number string, status model.OrderStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if order, exists := m.orders[number]; exists && isPending(order.Status) {
+		order.Status = status
+	}
+
+	return nil
+}
+
+func (m *Memory) CompleteOrder(ctx context.Context, number string, accrual float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, exists := m.orders[number]
+	if !exists || !isPending(order.Status) {
+		return nil
+	}
+
+	order.Status = model.OrderStatusProcessed
+	order.Accrual = &accrual
+	if accrual <= 0 {
+		return nil
+	}
+
+	m.balance(order.UserID).Current += accrual
+	m.ledger = append(m.ledger, model.LedgerEntry{
+		ID:          int64(len(m.ledger) + 1),
+		UserID:      order.UserID,
+		OrderNumber: number,
+		Operation:   model.LedgerOperationAccrual,
+		Amount:      accrual,
+		CreatedAt:   time.Now(),
+	})
+
+	return nil
+}
+
+func (m *Memory) GetBalance(ctx context.Context, userID uuid.UUID) (*model.Balance, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if balance, exists := m.balances[userID]; exists {
+		res := *balance
+		return &res, nil
+	}
+
+	return &model.Balance{UserID: userID}, nil
+}
+
+func (m *Memory) Withdraw(ctx context.Context, withdrawal *model.Withdrawal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, w := range m.withdrawals {
+		if w.OrderNumber == withdrawal.OrderNumber {
+			return model.ErrWithdrawalExists
+		}
+	}
+
+	balance := m.balance(withdrawal.UserID)
+	if math.Round(balance.Current*100) < math.Round(withdrawal.Sum*100) {
+		return model.ErrInsufficientFunds
+	}
+	balance.Current -= withdrawal.Sum
+	balance.Withdrawn += withdrawal.Sum
+
+	withdrawal.ID = int64(len(m.withdrawals) + 1)
+	if withdrawal.ProcessedAt.IsZero() {
+		withdrawal.ProcessedAt = time.Now()
+	}
+	m.withdrawals = append(m.withdrawals, *withdrawal)
+	m.ledger = append(m.ledger, model.LedgerEntry{
+		ID:          int64(len(m.ledger) + 1),
+		UserID:      withdrawal.UserID,
+		OrderNumber: withdrawal.OrderNumber,
+		Operation:   model.LedgerOperationWithdrawal,
+		Amount:      -withdrawal.Sum,
+		CreatedAt:   withdrawal.ProcessedAt,
+	})
+
+	return nil
+}
+
+func (m *Memory) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.Withdrawal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	withdrawals := make([]model.Withdrawal, 0)
+	for i := len(m.withdrawals) - 1; i >= 0; i-- {
+		if m.withdrawals[i].UserID == userID {
+			withdrawals = append(withdrawals, m.withdrawals[i])
+		}
+	}
+
+	return withdrawals, nil
+}
+
+// balance returns the user's balance, creating it if needed. The caller
+// must hold the write lock.
+func (m *Memory) balance(userID uuid.UUID) *model.Balance {
+	balance, exists := m.balances[userID]
+	if !exists {
+		balance = &model.Balance{UserID: userID}
+		m.balances[userID] = balance
+	}
+
+	return balance
+}
+
+func isPending(status model.OrderStatus) bool {
+	return status == model.OrderStatusNew || status == model.OrderStatusProcessing
+}
+
+var _ repository.GophermartRepo = (*Memory)(nil)
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (m *Memory) CreateSession(ctx context.Context, session *model.Session, token *model.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	if session.LastSeenAt.IsZero() {
+		session.LastSeenAt = now
+	}
+	storedSession := *session
+	m.sessions[session.ID] = &storedSession
+
+	token.SessionID = session.ID
+	token.UserID = session.UserID
+	token.CreatedAt = now
+	storedToken := *token
+	m.refreshTokens[token.TokenHash] = &storedToken
+
+	return nil
+}
+
+func (m *Memory) GetSession(ctx context.Context, id uuid.UUID) (*model.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, exists := m.sessions[id]
+	if !exists {
+		return nil, model.ErrNotFound
+	}
+	res := *session
+
+	return &res, nil
+}
+
+func (m *Memory) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sessions := make([]model.Session, 0)
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
+
+func (m *Memory) TouchSession(ctx context.Context, id uuid.UUID, meta model.SessionMeta) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session, exists := m.sessions[id]; exists {
+		session.LastSeenAt = time.Now()
+		session.IP = meta.IP
+		session.UserAgent = meta.UserAgent
+	}
+
+	return nil
+}
+
+func (m *Memory) RevokeSession(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revokeSession(id)
+
+	return nil
+}
+
+func (m *Memory) RevokeUserSession(ctx context.Context, userID, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[id]
+	if !exists || session.UserID != userID {
+		return model.ErrNotFound
+	}
+	m.revokeSession(id)
+
+	return nil
+}
+
+func (m *Memory) RotateRefreshToken(ctx context.Context, tokenHash string, next *model.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, exists := m.refreshTokens[tokenHash]
+	if !exists {
+		return model.ErrInvalidRefreshToken
+	}
+	if current.UsedAt != nil {
+		next.SessionID = current.SessionID
+		m.revokeSession(current.SessionID)
+		return model.ErrRefreshTokenReused
+	}
+
+	now := time.Now()
+	session := m.sessions[current.SessionID]
+	if session == nil || session.RevokedAt != nil || !current.ExpiresAt.After(now) {
+		return model.ErrInvalidRefreshToken
+	}
+
+	current.UsedAt = &now
+	next.SessionID = current.SessionID
+	next.UserID = current.UserID
+	next.CreatedAt = now
+	stored := *next
+	m.refreshTokens[next.TokenHash] = &stored
+
+	return nil
+}
+
+// revokeSession marks the session revoked. The caller must hold the write
+// lock.
+func (m *Memory) revokeSession(id uuid.UUID) {
+	if session, exists := m.sessions[id]; exists && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+}
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (m *Memory) SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, exists := m.users[userID]
+	if !exists || user.TOTPEnabled {
+		return model.ErrTOTPAlreadyEnabled
+	}
+	user.TOTPSecret = secret
+
+	return nil
+}
+
+func (m *Memory) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codes []model.RecoveryCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, exists := m.users[userID]
+	if !exists || user.TOTPSecret == "" || user.TOTPEnabled {
+		return model.ErrTOTPAlreadyEnabled
+	}
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+
+	now := time.Now()
+	stored := make([]model.RecoveryCode, len(codes))
+	for i, code := range codes {
+		code.CreatedAt = now
+		stored[i] = code
+	}
+	m.recoveryCodes[userID] = stored
+
+	return nil
+}
+
+func (m *Memory) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, exists := m.users[userID]
+	if !exists || user.TOTPLastStep >= step {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+
+	return true, nil
+}
+
+func (m *Memory) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	codes := m.recoveryCodes[userID]
+	for i := range codes {
+		if codes[i].CodeHash == codeHash && codes[i].UsedAt == nil {
+			now := time.Now()
+			codes[i].UsedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
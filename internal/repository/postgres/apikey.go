@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (p *Postgres) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	_, err := p.db.NewInsert().
+		Model(key).
+		Returning("created_at").
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while creating API key")
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	key := new(model.APIKey)
+	err := p.db.NewSelect().
+		Model(key).
+		Where("prefix = ?", prefix).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting API key")
+	}
+
+	return key, nil
+}
+
+func (p *Postgres) GetMerchantAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]model.APIKey, error) {
+	keys := make([]model.APIKey, 0)
+	err := p.db.NewSelect().
+		Model(&keys).
+		Where("merchant_id = ?", merchantID).
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting API keys")
+	}
+
+	return keys, nil
+}
+
+func (p *Postgres) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	_, err := p.db.NewUpdate().
+		Model((*model.APIKey)(nil)).
+		Set("last_used_at = now()").
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while updating API key")
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes a key of the merchant. Keys of other merchants are
+// reported as model.ErrNotFound.
+func (p *Postgres) RevokeAPIKey(ctx context.Context, merchantID, id uuid.UUID) error {
+	res, err := p.db.NewUpdate().
+		Model((*model.APIKey)(nil)).
+		Set("revoked_at = COALESCE(revoked_at, now())").
+		Where("id = ?", id).
+		Where("merchant_id = ?", merchantID).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while revoking API key")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while revoking API key")
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+// LinkMerchant records the customer's consent for the merchant. Linking
+// twice is not an error.
+func (p *Postgres) LinkMerchant(ctx context.Context, merchantID, customerID uuid.UUID) error {
+	_, err := p.db.NewInsert().
+		Model(&model.MerchantCustomer{MerchantID: merchantID, CustomerID: customerID}).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while linking merchant")
+	}
+
+	return nil
+}
+
+func (p *Postgres) UnlinkMerchant(ctx context.Context, merchantID, customerID uuid.UUID) error {
+	res, err := p.db.NewDelete().
+		Model((*model.MerchantCustomer)(nil)).
+		Where("merchant_id = ?", merchantID).
+		Where("customer_id = ?", customerID).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while unlinking merchant")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while unlinking merchant")
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetCustomerMerchants(ctx context.Context, customerID uuid.UUID) ([]model.MerchantCustomer, error) {
+	links := make([]model.MerchantCustomer, 0)
+	err := p.db.NewSelect().
+		Model(&links).
+		Relation("Merchant").
+		Where("mc.customer_id = ?", customerID).
+		Order("mc.created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting linked merchants")
+	}
+
+	return links, nil
+}
+
+func (p *Postgres) IsMerchantCustomer(ctx context.Context, merchantID, customerID uuid.UUID) (bool, error) {
+	linked, err := p.db.NewSelect().
+		Model((*model.MerchantCustomer)(nil)).
+		Where("merchant_id = ?", merchantID).
+		Where("customer_id = ?", customerID).
+		Exists(ctx)
+	if err != nil {
+		return false, errors.WithMessage(err, "error occurred while checking merchant link")
+	}
+
+	return linked, nil
+}
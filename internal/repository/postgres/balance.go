@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func credit(ctx context.Context, tx bun.Tx, userID uuid.UUID, number string, amount float64) error {
+	_, err := tx.NewInsert().
+		Model(&model.Balance{UserID: userID, Current: amount}).
+		On("CONFLICT (user_id) DO UPDATE").
+		Set("current = b.current + EXCLUDED.current").
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while crediting balance")
+	}
+
+	_, err = tx.NewInsert().
+		Model(&model.LedgerEntry{
+			UserID:      userID,
+			OrderNumber: number,
+			Operation:   model.LedgerOperationAccrual,
+			Amount:      amount,
+		}).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while writing ledger entry")
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetBalance(ctx context.Context, userID uuid.UUID) (*model.Balance, error) {
+	balance := &model.Balance{UserID: userID}
+	err := p.db.NewSelect().
+		Model(balance).
+		WherePK().
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return balance, nil
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting balance")
+	}
+
+	return balance, nil
+}
+
+// Withdraw debits the user's balance and records the withdrawal. The funds
+// are checked by the debiting update itself, so the comparison is done on
+// NUMERIC values and concurrent withdrawals can never overdraw the account.
+func (p *Postgres) Withdraw(ctx context.Context, withdrawal *model.Withdrawal) error {
+	return p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().
+			Model(&model.Balance{UserID: withdrawal.UserID}).
+			On("CONFLICT (user_id) DO NOTHING").
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while initializing balance")
+		}
+
+		res, err := tx.NewUpdate().
+			Model(&model.Balance{UserID: withdrawal.UserID}).
+			Set("current = current - ?::numeric", withdrawal.Sum).
+			Set("withdrawn = withdrawn + ?::numeric", withdrawal.Sum).
+			WherePK().
+			Where("current >= ?::numeric", withdrawal.Sum).
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while debiting balance")
+		}
+		debited, err := res.RowsAffected()
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while debiting balance")
+		}
+		if debited == 0 {
+			return model.ErrInsufficientFunds
+		}
+
+		_, err = tx.NewInsert().
+			Model(withdrawal).
+			Returning("id, processed_at").
+			Exec(ctx)
+		if isUniqueViolation(err) {
+			return model.ErrWithdrawalExists
+		}
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while creating withdrawal")
+		}
+
+		_, err = tx.NewInsert().
+			Model(&model.LedgerEntry{
+				UserID:      withdrawal.UserID,
+				OrderNumber: withdrawal.OrderNumber,
+				Operation:   model.LedgerOperationWithdrawal,
+				Amount:      -withdrawal.Sum,
+			}).
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while writing ledger entry")
+		}
+
+		return nil
+	})
+}
+
+func (p *Postgres) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.Withdrawal, error) {
+	withdrawals := make([]model.Withdrawal, 0)
+	err := p.db.NewSelect().
+		Model(&withdrawals).
+		Where("user_id = ?", userID).
+		OrderExpr("processed_at DESC, id DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting user withdrawals")
+	}
+
+	return withdrawals, nil
+}
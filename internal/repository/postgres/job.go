@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+// LeaseAccrualJobs hands out up to limit due jobs to owner for visibility.
+// Rows leased by other workers are skipped, so concurrent instances never
+// get the same job while its lease lasts.
+func (p *Postgres) LeaseAccrualJobs(ctx context.Context, owner string, limit int, visibility time.Duration) ([]model.AccrualJob, error) {
+	jobs := make([]model.AccrualJob, 0, limit)
+	err := p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		due := tx.NewSelect().
+			Model((*model.AccrualJob)(nil)).
+			Column("order_number").
+			Where("next_attempt_at <= now()").
+			Where("leased_until IS NULL OR leased_until <= now()").
+			Where("review_at IS NULL").
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED")
+
+		var numbers []string
+		err := tx.NewUpdate().
+			Model((*model.AccrualJob)(nil)).
+			Set("leased_until = now() + make_interval(secs => ?)", visibility.Seconds()).
+			Set("lease_owner = ?", owner).
+			Where("order_number IN (?)", due).
+			Returning("order_number").
+			Scan(ctx, &numbers)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while leasing accrual jobs")
+		}
+		if len(numbers) == 0 {
+			return nil
+		}
+
+		err = tx.NewSelect().
+			Model(&jobs).
+			Relation("Order").
+			Where("aj.order_number IN (?)", bun.In(numbers)).
+			Order("aj.next_attempt_at ASC").
+			Scan(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while getting accrual jobs")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// RetryAccrualJob releases the lease and schedules the next attempt as set
+// in job. It does nothing if the lease has passed to another worker.
+func (p *Postgres) RetryAccrualJob(ctx context.Context, job *model.AccrualJob) error {
+	_, err := p.db.NewUpdate().
+		Model((*model.AccrualJob)(nil)).
+		Set("attempts = ?", job.Attempts).
+		Set("next_attempt_at = ?", job.NextAttemptAt).
+		Set("last_error = ?", nullString(job.LastError)).
+		Set("leased_until = NULL").
+		Set("lease_owner = NULL").
+		Where("order_number = ?", job.OrderNumber).
+		Where("lease_owner = ?", job.LeaseOwner).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while rescheduling accrual job")
+	}
+
+	return nil
+}
+
+// FlagAccrualJob releases the lease and takes the job out of the queue until
+// someone reviews the order.
+func (p *Postgres) FlagAccrualJob(ctx context.Context, job *model.AccrualJob) error {
+	_, err := p.db.NewUpdate().
+		Model((*model.AccrualJob)(nil)).
+		Set("attempts = ?", job.Attempts).
+		Set("last_error = ?", nullString(job.LastError)).
+		Set("review_at = now()").
+		Set("leased_until = NULL").
+		Set("lease_owner = NULL").
+		Where("order_number = ?", job.OrderNumber).
+		Where("lease_owner = ?", job.LeaseOwner).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while flagging accrual job")
+	}
+
+	return nil
+}
+
+// GetFlaggedAccrualJobs returns the jobs awaiting manual review, oldest
+// first.
+func (p *Postgres) GetFlaggedAccrualJobs(ctx context.Context, limit int) ([]model.AccrualJob, error) {
+	jobs := make([]model.AccrualJob, 0)
+	err := p.db.NewSelect().
+		Model(&jobs).
+		Relation("Order").
+		Where("aj.review_at IS NOT NULL").
+		Order("aj.review_at ASC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting flagged accrual jobs")
+	}
+
+	return jobs, nil
+}
+
+// DeleteAccrualJob removes the job of an order that reached a final status.
+func (p *Postgres) DeleteAccrualJob(ctx context.Context, number, owner string) error {
+	_, err := p.db.NewDelete().
+		Model((*model.AccrualJob)(nil)).
+		Where("order_number = ?", number).
+		Where("lease_owner = ?", owner).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while deleting accrual job")
+	}
+
+	return nil
+}
+
+// FinishAccrualJob removes the job of an order that was finished outside the
+// queue, whoever holds its lease and whether or not it is flagged.
+func (p *Postgres) FinishAccrualJob(ctx context.Context, number string) error {
+	_, err := p.db.NewDelete().
+		Model((*model.AccrualJob)(nil)).
+		Where("order_number = ?", number).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while deleting accrual job")
+	}
+
+	return nil
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
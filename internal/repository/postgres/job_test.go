@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+// testRepo is connected to TEST_DATABASE_URI. The tests are skipped when it
+// is not set, and they leave their rows behind, so don't point it at a
+// database holding real data.
+var testRepo *Postgres
+
+func TestMain(m *testing.M) {
+	if dsn := os.Getenv("TEST_DATABASE_URI"); dsn != "" {
+		os.Setenv("CONFIG_PATH", "../../../configuration/config.yaml")
+		os.Setenv("LOG_LEVEL", "error")
+		os.Setenv("DATABASE_URI", dsn)
+		util.InitLogger(util.GetConfig().Logger)
+
+		repo, err := Connect(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		if err = repo.MigrateDBUp(context.Background()); err != nil {
+			panic(err)
+		}
+		testRepo = repo
+	}
+
+	code := m.Run()
+	if testRepo != nil {
+		testRepo.Close()
+	}
+	os.Exit(code)
+}
+
+func newTestRepo(t *testing.T) *Postgres {
+	t.Helper()
+	if testRepo == nil {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+
+	return testRepo
+}
+
+// createTestOrders creates n new orders, each queueing an accrual job, and
+// returns their numbers.
+func createTestOrders(t *testing.T, p *Postgres, n int) []string {
+	t.Helper()
+	ctx := context.Background()
+
+	user := &model.User{ID: uuid.New(), Login: "jobs-" + uuid.NewString(), PasswordHash: "-", Role: model.RoleUser}
+	require.NoError(t, p.CreateUser(ctx, user))
+
+	numbers := make([]string, 0, n)
+	prefix := time.Now().UnixNano()
+	for i := 0; i < n; i++ {
+		number := fmt.Sprintf("%d%03d", prefix, i)
+		require.NoError(t, p.CreateOrder(ctx, &model.Order{Number: number, UserID: user.ID, Status: model.OrderStatusNew}))
+		numbers = append(numbers, number)
+	}
+
+	return numbers
+}
+
+func TestLeaseAccrualJobsConcurrently(t *testing.T) {
+	p := newTestRepo(t)
+	ctx := context.Background()
+	numbers := createTestOrders(t, p, 50)
+
+	const leasers = 8
+	var (
+		mu     sync.Mutex
+		leased = make(map[string][]string)
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < leasers; i++ {
+		wg.Add(1)
+		go func(owner string) {
+			defer wg.Done()
+			jobs, err := p.LeaseAccrualJobs(ctx, owner, len(numbers), time.Minute)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, job := range jobs {
+				assert.Equal(t, owner, job.LeaseOwner)
+				leased[job.OrderNumber] = append(leased[job.OrderNumber], owner)
+			}
+		}(fmt.Sprintf("%s-%d", t.Name(), i))
+	}
+	wg.Wait()
+
+	for _, number := range numbers {
+		assert.Len(t, leased[number], 1, "job %s must be leased exactly once", number)
+	}
+	for number, owners := range leased {
+		assert.Len(t, owners, 1, "job %s leased by %v", number, owners)
+	}
+}
+
+func TestAccrualJobLeaseExpiry(t *testing.T) {
+	p := newTestRepo(t)
+	ctx := context.Background()
+	number := createTestOrders(t, p, 1)[0]
+	ownerA, ownerB := t.Name()+"-a", t.Name()+"-b"
+
+	lease := func(owner string) *model.AccrualJob {
+		t.Helper()
+		jobs, err := p.LeaseAccrualJobs(ctx, owner, 1000, time.Minute)
+		require.NoError(t, err)
+		for _, job := range jobs {
+			if job.OrderNumber == number {
+				return &job
+			}
+		}
+		return nil
+	}
+	stored := func() *model.AccrualJob {
+		t.Helper()
+		job := new(model.AccrualJob)
+		require.NoError(t, p.db.NewSelect().Model(job).Where("order_number = ?", number).Scan(ctx))
+		return job
+	}
+
+	first := lease(ownerA)
+	require.NotNil(t, first)
+	require.NotNil(t, first.Order)
+	assert.Nil(t, lease(ownerB), "leased job is invisible to other workers")
+
+	_, err := p.db.NewUpdate().
+		Model((*model.AccrualJob)(nil)).
+		Set("leased_until = now() - interval '1 second'").
+		Where("order_number = ?", number).
+		Exec(ctx)
+	require.NoError(t, err)
+	second := lease(ownerB)
+	require.NotNil(t, second, "expired lease is handed to another worker")
+	assert.Equal(t, ownerB, stored().LeaseOwner)
+
+	// The first worker still holds its stale copy of the job.
+	first.Attempts = 5
+	first.NextAttemptAt = time.Now()
+	require.NoError(t, p.RetryAccrualJob(ctx, first))
+	require.NoError(t, p.FlagAccrualJob(ctx, first))
+	require.NoError(t, p.DeleteAccrualJob(ctx, number, ownerA))
+	job := stored()
+	assert.Equal(t, ownerB, job.LeaseOwner, "a stale owner can't release the job")
+	assert.Zero(t, job.Attempts)
+	assert.Nil(t, job.ReviewAt, "a stale owner can't flag the job")
+
+	second.Attempts = 1
+	second.LastError = "still processing"
+	require.NoError(t, p.FlagAccrualJob(ctx, second))
+	job = stored()
+	assert.Empty(t, job.LeaseOwner)
+	assert.NotNil(t, job.ReviewAt)
+	assert.Nil(t, lease(ownerA), "flagged jobs are not leased")
+
+	require.NoError(t, p.FinishAccrualJob(ctx, number))
+	exists, err := p.db.NewSelect().Model((*model.AccrualJob)(nil)).Where("order_number = ?", number).Exists(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
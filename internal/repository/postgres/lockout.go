@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+// loginFailures is the failure count after the attempt being acquired. It
+// starts over when the last failure and the last lock are older than the
+// window, given as ?0.
+const loginFailures = `(CASE WHEN GREATEST(la.updated_at, la.locked_until) < now() - make_interval(secs => ?0) ` +
+	`THEN 1 ELSE la.failures + 1 END)`
+
+// AcquireLoginAttempt counts an attempt for the key before its credentials
+// are checked. The check and the increment are a single upsert, so parallel
+// attempts can't exceed the threshold: the attempt that reaches it locks the
+// key, and attempts made while the key is locked are rejected with
+// model.LoginLockedError without being counted.
+func (p *Postgres) AcquireLoginAttempt(ctx context.Context, key string, policy model.LockoutPolicy) (*model.LoginAttempt, error) {
+	attempt := &model.LoginAttempt{Key: key, Failures: 1}
+	if policy.Locks(attempt.Failures) {
+		until := time.Now().Add(policy.Delay(attempt.Failures))
+		attempt.LockedUntil = &until
+	}
+
+	res, err := p.db.NewInsert().
+		Model(attempt).
+		On("CONFLICT (key) DO UPDATE").
+		Set("failures = "+loginFailures, policy.Window.Seconds()).
+		Set(`locked_until = CASE
+			WHEN `+loginFailures+` >= ?1
+				THEN now() + make_interval(secs => LEAST(?2 * power(2, LEAST(`+loginFailures+` - ?1, ?4)), ?3))
+			WHEN `+loginFailures+` = 1 THEN NULL
+			ELSE la.locked_until END`,
+			policy.Window.Seconds(), policy.Threshold, policy.BaseDelay.Seconds(), policy.MaxDelay.Seconds(), model.MaxLockoutDoublings).
+		Set("updated_at = now()").
+		Where("la.locked_until IS NULL OR la.locked_until <= now()").
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while acquiring login attempt")
+	}
+	acquired, err := res.RowsAffected()
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while acquiring login attempt")
+	}
+	if acquired > 0 {
+		return attempt, nil
+	}
+
+	locked := &model.LoginAttempt{Key: key}
+	err = p.db.NewSelect().
+		Model(locked).
+		WherePK().
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.WithMessage(err, "error occurred while getting login attempt")
+	}
+	until := time.Now()
+	if locked.LockedUntil != nil {
+		until = *locked.LockedUntil
+	}
+
+	return nil, &model.LoginLockedError{Until: until}
+}
+
+// ReleaseLoginAttempt takes back an acquired attempt whose credentials turned
+// out to be valid, including the lock it placed.
+func (p *Postgres) ReleaseLoginAttempt(ctx context.Context, attempt *model.LoginAttempt) error {
+	_, err := p.db.NewUpdate().
+		Model((*model.LoginAttempt)(nil)).
+		Set("failures = GREATEST(failures - 1, 0)").
+		Set("locked_until = CASE WHEN locked_until = ? THEN NULL ELSE locked_until END", attempt.LockedUntil).
+		Where("key = ?", attempt.Key).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while releasing login attempt")
+	}
+
+	return nil
+}
+
+// PruneLoginAttempts deletes the counters that would start over on the next
+// attempt anyway.
+func (p *Postgres) PruneLoginAttempts(ctx context.Context, window time.Duration) (int64, error) {
+	res, err := p.db.NewDelete().
+		Model((*model.LoginAttempt)(nil)).
+		Where("GREATEST(updated_at, locked_until) < now() - make_interval(secs => ?)", window.Seconds()).
+		Exec(ctx)
+	if err != nil {
+		return 0, errors.WithMessage(err, "error occurred while pruning login attempts")
+	}
+
+	pruned, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.WithMessage(err, "error occurred while pruning login attempts")
+	}
+
+	return pruned, nil
+}
+
+// AddAuthEvent records a lockout or an unlock.
+func (p *Postgres) AddAuthEvent(ctx context.Context, event *model.AuthEvent) error {
+	return insertAuthEvent(ctx, p.db, event)
+}
+
+// ResetLoginAttempts clears the failure counter of the key and records the
+// event if one is given.
+func (p *Postgres) ResetLoginAttempts(ctx context.Context, key string, event *model.AuthEvent) error {
+	return p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*model.LoginAttempt)(nil)).
+			Where("key = ?", key).
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while resetting login attempts")
+		}
+
+		if event == nil {
+			return nil
+		}
+		return insertAuthEvent(ctx, tx, event)
+	})
+}
+
+func (p *Postgres) GetAuthEvents(ctx context.Context, login string, limit int) ([]model.AuthEvent, error) {
+	events := make([]model.AuthEvent, 0)
+	err := p.db.NewSelect().
+		Model(&events).
+		Where("login = ?", login).
+		OrderExpr("created_at DESC, id DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting auth events")
+	}
+
+	return events, nil
+}
+
+func insertAuthEvent(ctx context.Context, db bun.IDB, event *model.AuthEvent) error {
+	_, err := db.NewInsert().
+		Model(event).
+		Returning("id, created_at").
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while recording auth event")
+	}
+
+	return nil
+}
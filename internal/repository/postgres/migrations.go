@@ -2,22 +2,71 @@ package postgres
 
 import (
 	"context"
+	"embed"
+	"io/fs"
 
+	"github.com/pkg/errors"
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"github.com/ypxd99/yandex-diplom-56/util"
 )
 
-func MigrateDBUp(ctx context.Context) error {
-	db, err := Connect(ctx)
+//go:embed migrations/*.sql
+var embedMigrations embed.FS
+
+// lockTimeout* bound how long a replica waits for the migration advisory
+// lock held by another replica: 60 attempts every 5 seconds.
+const (
+	lockTimeoutPeriod   = 5
+	lockTimeoutAttempts = 60
+)
+
+func (p *Postgres) newMigrationProvider() (*goose.Provider, error) {
+	migrations, err := fs.Sub(embedMigrations, "migrations")
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while opening migrations")
+	}
+
+	locker, err := lock.NewPostgresSessionLocker(lock.WithLockTimeout(lockTimeoutPeriod, lockTimeoutAttempts))
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while creating migration locker")
+	}
+
+	return goose.NewProvider(goose.DialectPostgres, p.db.DB, migrations,
+		goose.WithSessionLocker(locker),
+		goose.WithLogger(util.GetLogger()),
+	)
+}
+
+// MigrateDBUp applies all pending migrations. A Postgres advisory lock is
+// held for the duration, so replicas starting together apply them once.
+func (p *Postgres) MigrateDBUp(ctx context.Context) error {
+	provider, err := p.newMigrationProvider()
 	if err != nil {
 		return err
 	}
-	return goose.UpContext(ctx, db.db.DB, "./migration")
+
+	results, err := provider.Up(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while applying migrations")
+	}
+	for _, res := range results {
+		util.GetLogger().Infof("applied migration %s in %s", res.Source.Path, res.Duration)
+	}
+
+	return nil
 }
 
-func MigrateDBDown(ctx context.Context) error {
-	db, err := Connect(ctx)
+func (p *Postgres) MigrateDBDown(ctx context.Context) error {
+	provider, err := p.newMigrationProvider()
 	if err != nil {
 		return err
 	}
-	return goose.DownContext(ctx, db.db.DB, "./migration")
+
+	_, err = provider.Down(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while rolling back migration")
+	}
+
+	return nil
 }
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS users
+(
+    id            UUID PRIMARY KEY,
+    login         TEXT        NOT NULL,
+    password_hash TEXT        NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT users_login_key UNIQUE (login)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS orders
+(
+    number      TEXT PRIMARY KEY,
+    user_id     UUID           NOT NULL REFERENCES users (id),
+    status      TEXT           NOT NULL DEFAULT 'NEW',
+    accrual     NUMERIC(14, 2),
+    uploaded_at TIMESTAMPTZ    NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS orders_user_id_uploaded_at_idx ON orders (user_id, uploaded_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS balances
+(
+    user_id   UUID PRIMARY KEY REFERENCES users (id),
+    current   NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK (current >= 0),
+    withdrawn NUMERIC(14, 2) NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS ledger
+(
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      UUID           NOT NULL REFERENCES users (id),
+    order_number TEXT           NOT NULL,
+    operation    TEXT           NOT NULL,
+    amount       NUMERIC(14, 2) NOT NULL,
+    created_at   TIMESTAMPTZ    NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ledger_user_id_idx ON ledger (user_id);
+
+CREATE INDEX IF NOT EXISTS orders_status_idx ON orders (status) WHERE status IN ('NEW', 'PROCESSING');
+
+-- +goose Down
+DROP INDEX IF EXISTS orders_status_idx;
+DROP TABLE IF EXISTS ledger;
+DROP TABLE IF EXISTS balances;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS withdrawals
+(
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      UUID           NOT NULL REFERENCES users (id),
+    order_number TEXT           NOT NULL,
+    sum          NUMERIC(14, 2) NOT NULL CHECK (sum > 0),
+    processed_at TIMESTAMPTZ    NOT NULL DEFAULT now(),
+    CONSTRAINT withdrawals_order_number_key UNIQUE (order_number)
+);
+
+CREATE INDEX IF NOT EXISTS withdrawals_user_id_processed_at_idx ON withdrawals (user_id, processed_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS withdrawals;
@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens
+(
+    id         BIGSERIAL PRIMARY KEY,
+    session_id UUID        NOT NULL REFERENCES sessions (id),
+    user_id    UUID        NOT NULL REFERENCES users (id),
+    token_hash TEXT        NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ,
+    CONSTRAINT refresh_tokens_token_hash_key UNIQUE (token_hash)
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_id_idx ON refresh_tokens (session_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions;
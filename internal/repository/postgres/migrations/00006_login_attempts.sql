@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS login_attempts
+(
+    key          TEXT PRIMARY KEY,
+    failures     INTEGER     NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS auth_events
+(
+    id           BIGSERIAL PRIMARY KEY,
+    key          TEXT        NOT NULL,
+    login        TEXT        NOT NULL,
+    ip           TEXT        NOT NULL,
+    event        TEXT        NOT NULL,
+    failures     INTEGER     NOT NULL,
+    locked_until TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS auth_events_login_idx ON auth_events (login, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_events;
+DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id           UUID PRIMARY KEY,
+    merchant_id  UUID        NOT NULL REFERENCES users (id),
+    name         TEXT        NOT NULL,
+    prefix       TEXT        NOT NULL,
+    secret_hash  TEXT        NOT NULL,
+    scopes       TEXT[]      NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    CONSTRAINT api_keys_prefix_key UNIQUE (prefix)
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_merchant_id_idx ON api_keys (merchant_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_keys;
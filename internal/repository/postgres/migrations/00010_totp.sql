@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS totp_secret    TEXT,
+    ADD COLUMN IF NOT EXISTS totp_enabled   BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS totp_last_step BIGINT  NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (id),
+    code_hash  TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS recovery_codes_user_id_idx ON recovery_codes (user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS recovery_codes;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS totp_last_step,
+    DROP COLUMN IF EXISTS totp_enabled,
+    DROP COLUMN IF EXISTS totp_secret;
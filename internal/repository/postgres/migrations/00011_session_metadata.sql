@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS device       TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS user_agent   TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ip           TEXT        NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- +goose Down
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS device;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS accrual_jobs
+(
+    order_number    TEXT PRIMARY KEY REFERENCES orders (number),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    leased_until    TIMESTAMPTZ,
+    lease_owner     TEXT,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS accrual_jobs_next_attempt_at_idx ON accrual_jobs (next_attempt_at);
+
+INSERT INTO accrual_jobs (order_number, created_at)
+SELECT number, uploaded_at
+FROM orders
+WHERE status IN ('NEW', 'PROCESSING')
+ON CONFLICT DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS accrual_jobs;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE accrual_jobs
+    ADD COLUMN IF NOT EXISTS review_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS accrual_jobs_review_at_idx ON accrual_jobs (review_at) WHERE review_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS accrual_jobs_review_at_idx;
+ALTER TABLE accrual_jobs
+    DROP COLUMN IF EXISTS review_at;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS merchant_customers
+(
+    merchant_id UUID        NOT NULL REFERENCES users (id),
+    customer_id UUID        NOT NULL REFERENCES users (id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (merchant_id, customer_id)
+);
+
+CREATE INDEX IF NOT EXISTS merchant_customers_customer_id_idx ON merchant_customers (customer_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS merchant_customers;
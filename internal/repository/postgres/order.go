@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+// CreateOrder stores the order and queues its first accrual poll.
+func (p *Postgres) CreateOrder(ctx context.Context, order *model.Order) error {
+	return p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewInsert().
+			Model(order).
+			On("CONFLICT (number) DO NOTHING").
+			Returning("uploaded_at").
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while creating order")
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while getting affected rows")
+		}
+		if rows == 0 {
+			return model.ErrOrderExists
+		}
+
+		_, err = tx.NewInsert().
+			Model(&model.AccrualJob{OrderNumber: order.Number}).
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while queueing accrual job")
+		}
+
+		return nil
+	})
+}
+
+func (p *Postgres) GetOrder(ctx context.Context, number string) (*model.Order, error) {
+	order := new(model.Order)
+	err := p.db.NewSelect().
+		Model(order).
+		Where("number = ?", number).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting order")
+	}
+
+	return order, nil
+}
+
+func (p *Postgres) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.Order, error) {
+	orders := make([]model.Order, 0)
+	err := p.db.NewSelect().
+		Model(&orders).
+		Where("user_id = ?", userID).
+		Order("uploaded_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting user orders")
+	}
+
+	return orders, nil
+}
+
+func (p *Postgres) UpdateOrderStatus(ctx context.Context, number string, status model.OrderStatus) error {
+	_, err := p.db.NewUpdate().
+		Model((*model.Order)(nil)).
+		Set("status = ?", status).
+		Where("number = ?", number).
+		Where("status IN (?)", bun.In([]model.OrderStatus{model.OrderStatusNew, model.OrderStatusProcessing})).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while updating order status")
+	}
+
+	return nil
+}
+
+// CompleteOrder marks the order as PROCESSED and credits the accrual to its
+// owner in one transaction. Orders that are already final are left intact,
+// so repeated calls never credit twice.
+func (p *Postgres) CompleteOrder(ctx context.Context, number string, accrual float64) error {
+	return p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		order := new(model.Order)
+		res, err := tx.NewUpdate().
+			Model(order).
+			Set("status = ?", model.OrderStatusProcessed).
+			Set("accrual = ?", accrual).
+			Where("number = ?", number).
+			Where("status IN (?)", bun.In([]model.OrderStatus{model.OrderStatusNew, model.OrderStatusProcessing})).
+			Returning("user_id").
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while completing order")
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while getting affected rows")
+		}
+		if rows == 0 || accrual <= 0 {
+			return nil
+		}
+
+		return credit(ctx, tx, order.UserID, number, accrual)
+	})
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (p *Postgres) CreateSession(ctx context.Context, session *model.Session, token *model.RefreshToken) error {
+	return p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewInsert().
+			Model(session).
+			Returning("created_at, last_seen_at").
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while creating session")
+		}
+
+		token.SessionID = session.ID
+		token.UserID = session.UserID
+		_, err = tx.NewInsert().
+			Model(token).
+			Returning("id, created_at").
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while creating refresh token")
+		}
+
+		return nil
+	})
+}
+
+func (p *Postgres) GetSession(ctx context.Context, id uuid.UUID) (*model.Session, error) {
+	session := &model.Session{ID: id}
+	err := p.db.NewSelect().
+		Model(session).
+		WherePK().
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting session")
+	}
+
+	return session, nil
+}
+
+// GetUserSessions returns the sessions of the user that have not been
+// revoked, most recently used first.
+func (p *Postgres) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	sessions := make([]model.Session, 0)
+	err := p.db.NewSelect().
+		Model(&sessions).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Order("last_seen_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting sessions")
+	}
+
+	return sessions, nil
+}
+
+// TouchSession records the client a session was last used from.
+func (p *Postgres) TouchSession(ctx context.Context, id uuid.UUID, meta model.SessionMeta) error {
+	_, err := p.db.NewUpdate().
+		Model((*model.Session)(nil)).
+		Set("last_seen_at = now()").
+		Set("ip = ?", meta.IP).
+		Set("user_agent = ?", meta.UserAgent).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while updating session")
+	}
+
+	return nil
+}
+
+func (p *Postgres) RevokeSession(ctx context.Context, id uuid.UUID) error {
+	return revokeSession(ctx, p.db, id)
+}
+
+// RevokeUserSession revokes a session of the user. Sessions of other users
+// are reported as model.ErrNotFound.
+func (p *Postgres) RevokeUserSession(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := p.db.NewUpdate().
+		Model((*model.Session)(nil)).
+		Set("revoked_at = COALESCE(revoked_at, now())").
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while revoking session")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while revoking session")
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+// RotateRefreshToken consumes the token with the given hash and stores next
+// in the same session. Presenting an already used token revokes the whole
+// session and reports model.ErrRefreshTokenReused with next.SessionID set to
+// the revoked session.
+func (p *Postgres) RotateRefreshToken(ctx context.Context, tokenHash string, next *model.RefreshToken) error {
+	var reused bool
+	err := p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		current := new(model.RefreshToken)
+		err := tx.NewSelect().
+			Model(current).
+			Where("token_hash = ?", tokenHash).
+			For("UPDATE").
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while getting refresh token")
+		}
+
+		if current.UsedAt != nil {
+			reused = true
+			next.SessionID = current.SessionID
+			return revokeSession(ctx, tx, current.SessionID)
+		}
+
+		session := &model.Session{ID: current.SessionID}
+		err = tx.NewSelect().
+			Model(session).
+			WherePK().
+			For("UPDATE").
+			Scan(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while getting session")
+		}
+		if session.RevokedAt != nil || !current.ExpiresAt.After(time.Now()) {
+			return model.ErrInvalidRefreshToken
+		}
+
+		_, err = tx.NewUpdate().
+			Model(current).
+			Set("used_at = now()").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while consuming refresh token")
+		}
+
+		next.SessionID = current.SessionID
+		next.UserID = current.UserID
+		_, err = tx.NewInsert().
+			Model(next).
+			Returning("id, created_at").
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while creating refresh token")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if reused {
+		return model.ErrRefreshTokenReused
+	}
+
+	return nil
+}
+
+func revokeSession(ctx context.Context, db bun.IDB, id uuid.UUID) error {
+	_, err := db.NewUpdate().
+		Model((*model.Session)(nil)).
+		Set("revoked_at = now()").
+		Where("id = ?", id).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while revoking session")
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+// SetTOTPSecret stores a pending secret. It fails with
+// model.ErrTOTPAlreadyEnabled once 2FA has been confirmed.
+func (p *Postgres) SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	res, err := p.db.NewUpdate().
+		Model((*model.User)(nil)).
+		Set("totp_secret = ?", secret).
+		Where("id = ?", userID).
+		Where("NOT totp_enabled").
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while setting TOTP secret")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while setting TOTP secret")
+	}
+	if affected == 0 {
+		return model.ErrTOTPAlreadyEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP confirms the pending secret and replaces the recovery codes.
+func (p *Postgres) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codes []model.RecoveryCode) error {
+	return p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewUpdate().
+			Model((*model.User)(nil)).
+			Set("totp_enabled = true").
+			Set("totp_last_step = ?", step).
+			Where("id = ?", userID).
+			Where("totp_secret IS NOT NULL").
+			Where("NOT totp_enabled").
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while enabling TOTP")
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while enabling TOTP")
+		}
+		if affected == 0 {
+			return model.ErrTOTPAlreadyEnabled
+		}
+
+		_, err = tx.NewDelete().
+			Model((*model.RecoveryCode)(nil)).
+			Where("user_id = ?", userID).
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while deleting recovery codes")
+		}
+
+		_, err = tx.NewInsert().
+			Model(&codes).
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while creating recovery codes")
+		}
+
+		return nil
+	})
+}
+
+// UseTOTPStep records the step of an accepted code. It reports false if a
+// code of this or a later step was already used, which stops replays.
+func (p *Postgres) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	res, err := p.db.NewUpdate().
+		Model((*model.User)(nil)).
+		Set("totp_last_step = ?", step).
+		Where("id = ?", userID).
+		Where("totp_last_step < ?", step).
+		Exec(ctx)
+	if err != nil {
+		return false, errors.WithMessage(err, "error occurred while using TOTP code")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.WithMessage(err, "error occurred while using TOTP code")
+	}
+
+	return affected > 0, nil
+}
+
+// UseRecoveryCode consumes an unused recovery code and reports whether it
+// existed.
+func (p *Postgres) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	res, err := p.db.NewUpdate().
+		Model((*model.RecoveryCode)(nil)).
+		Set("used_at = now()").
+		Where("user_id = ?", userID).
+		Where("code_hash = ?", codeHash).
+		Where("used_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return false, errors.WithMessage(err, "error occurred while using recovery code")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.WithMessage(err, "error occurred while using recovery code")
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (p *Postgres) CreateUser(ctx context.Context, user *model.User) error {
+	_, err := p.db.NewInsert().
+		Model(user).
+		Returning("created_at").
+		Exec(ctx)
+	if isUniqueViolation(err) {
+		return model.ErrLoginTaken
+	}
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while creating user")
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetUser(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	user := &model.User{ID: id}
+	err := p.db.NewSelect().
+		Model(user).
+		WherePK().
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting user")
+	}
+
+	return user, nil
+}
+
+func (p *Postgres) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
+	user := new(model.User)
+	err := p.db.NewSelect().
+		Model(user).
+		Where("login = ?", login).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while getting user by login")
+	}
+
+	return user, nil
+}
+
+// UpdatePassword stores the new password hash, bumps the token version and
+// revokes every session of the user. It returns the new token version.
+func (p *Postgres) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) (int, error) {
+	var version int
+	err := p.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewUpdate().
+			Model((*model.User)(nil)).
+			Set("password_hash = ?", passwordHash).
+			Set("token_version = token_version + 1").
+			Where("id = ?", userID).
+			Returning("token_version").
+			Scan(ctx, &version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrNotFound
+		}
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while updating password")
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*model.Session)(nil)).
+			Set("revoked_at = now()").
+			Where("user_id = ?", userID).
+			Where("revoked_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return errors.WithMessage(err, "error occurred while revoking sessions")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// UpdateUserRole changes the role and bumps the token version, so tokens
+// carrying the old role stop being accepted.
+func (p *Postgres) UpdateUserRole(ctx context.Context, userID uuid.UUID, role model.Role) error {
+	res, err := p.db.NewUpdate().
+		Model((*model.User)(nil)).
+		Set("role = ?", role).
+		Set("token_version = token_version + 1").
+		Where("id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while updating user role")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while updating user role")
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Field('C') == "23505"
+	}
+
+	return false
+}
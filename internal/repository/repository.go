@@ -1,8 +1,67 @@
 package repository
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
 
 type GophermartRepo interface {
 	Close() error
 	Status(ctx context.Context) (bool, error)
+
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUser(ctx context.Context, id uuid.UUID) (*model.User, error)
+	GetUserByLogin(ctx context.Context, login string) (*model.User, error)
+	UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) (int, error)
+	UpdateUserRole(ctx context.Context, userID uuid.UUID, role model.Role) error
+
+	SetTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, codes []model.RecoveryCode) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+
+	AcquireLoginAttempt(ctx context.Context, key string, policy model.LockoutPolicy) (*model.LoginAttempt, error)
+	ReleaseLoginAttempt(ctx context.Context, attempt *model.LoginAttempt) error
+	ResetLoginAttempts(ctx context.Context, key string, event *model.AuthEvent) error
+	PruneLoginAttempts(ctx context.Context, window time.Duration) (int64, error)
+	AddAuthEvent(ctx context.Context, event *model.AuthEvent) error
+	GetAuthEvents(ctx context.Context, login string, limit int) ([]model.AuthEvent, error)
+
+	CreateSession(ctx context.Context, session *model.Session, token *model.RefreshToken) error
+	GetSession(ctx context.Context, id uuid.UUID) (*model.Session, error)
+	GetUserSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID, meta model.SessionMeta) error
+	RevokeSession(ctx context.Context, id uuid.UUID) error
+	RevokeUserSession(ctx context.Context, userID, id uuid.UUID) error
+	RotateRefreshToken(ctx context.Context, tokenHash string, next *model.RefreshToken) error
+
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	GetMerchantAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]model.APIKey, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID) error
+	RevokeAPIKey(ctx context.Context, merchantID, id uuid.UUID) error
+	LinkMerchant(ctx context.Context, merchantID, customerID uuid.UUID) error
+	UnlinkMerchant(ctx context.Context, merchantID, customerID uuid.UUID) error
+	GetCustomerMerchants(ctx context.Context, customerID uuid.UUID) ([]model.MerchantCustomer, error)
+	IsMerchantCustomer(ctx context.Context, merchantID, customerID uuid.UUID) (bool, error)
+
+	CreateOrder(ctx context.Context, order *model.Order) error
+	GetOrder(ctx context.Context, number string) (*model.Order, error)
+	GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.Order, error)
+	UpdateOrderStatus(ctx context.Context, number string, status model.OrderStatus) error
+	CompleteOrder(ctx context.Context, number string, accrual float64) error
+
+	LeaseAccrualJobs(ctx context.Context, owner string, limit int, visibility time.Duration) ([]model.AccrualJob, error)
+	RetryAccrualJob(ctx context.Context, job *model.AccrualJob) error
+	FlagAccrualJob(ctx context.Context, job *model.AccrualJob) error
+	GetFlaggedAccrualJobs(ctx context.Context, limit int) ([]model.AccrualJob, error)
+	DeleteAccrualJob(ctx context.Context, number, owner string) error
+	FinishAccrualJob(ctx context.Context, number string) error
+
+	GetBalance(ctx context.Context, userID uuid.UUID) (*model.Balance, error)
+	Withdraw(ctx context.Context, withdrawal *model.Withdrawal) error
+	GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.Withdrawal, error)
 }
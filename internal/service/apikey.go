@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+const (
+	apiKeyPrefix      = "gm"
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+	apiKeyParts       = 3
+	apiKeySeparator   = "_"
+)
+
+// CreateAPIKey issues a new API key for the merchant. Only accounts with the
+// merchant role may have keys. The returned key is the only place the secret
+// appears in plain text.
+func (s *Service) CreateAPIKey(ctx context.Context, merchantID uuid.UUID, req model.APIKeyRequest) (*model.IssuedAPIKey, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || !validScopes(req.Scopes) {
+		return nil, model.ErrInvalidAPIKeyRequest
+	}
+
+	merchant, err := s.repo.GetUser(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant.Role != model.RoleMerchant {
+		return nil, model.ErrNotMerchant
+	}
+
+	prefix, err := randomString(apiKeyPrefixBytes, hex.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomString(apiKeySecretBytes, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+
+	key := model.APIKey{
+		ID:         uuid.New(),
+		MerchantID: merchantID,
+		Name:       req.Name,
+		Prefix:     prefix,
+		SecretHash: hashToken(secret),
+		Scopes:     dedupScopes(req.Scopes),
+	}
+	if err = s.repo.CreateAPIKey(ctx, &key); err != nil {
+		return nil, err
+	}
+
+	return &model.IssuedAPIKey{
+		APIKey: key,
+		Key:    strings.Join([]string{apiKeyPrefix, prefix, secret}, apiKeySeparator),
+	}, nil
+}
+
+func (s *Service) GetAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]model.APIKey, error) {
+	return s.repo.GetMerchantAPIKeys(ctx, merchantID)
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, merchantID, id uuid.UUID) error {
+	return s.repo.RevokeAPIKey(ctx, merchantID, id)
+}
+
+// AuthenticateAPIKey checks the key and resolves the customer the merchant
+// acts for. It returns model.ErrInvalidAPIKey for unknown or revoked keys and
+// model.ErrCustomerNotLinked unless the customer has linked the merchant. An
+// unknown customer is reported the same way, so keys can't probe logins.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, rawKey, customerLogin string) (*model.APIKey, uuid.UUID, error) {
+	parts := strings.SplitN(rawKey, apiKeySeparator, apiKeyParts)
+	if len(parts) != apiKeyParts || parts[0] != apiKeyPrefix {
+		return nil, uuid.Nil, model.ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetAPIKeyByPrefix(ctx, parts[1])
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, uuid.Nil, model.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if key.RevokedAt != nil ||
+		subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashToken(parts[2]))) != 1 {
+		return nil, uuid.Nil, model.ErrInvalidAPIKey
+	}
+
+	if err = s.repo.TouchAPIKey(ctx, key.ID); err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	customer, err := s.repo.GetUserByLogin(ctx, customerLogin)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, uuid.Nil, model.ErrCustomerNotLinked
+	}
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	linked, err := s.repo.IsMerchantCustomer(ctx, key.MerchantID, customer.ID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if !linked {
+		return nil, uuid.Nil, model.ErrCustomerNotLinked
+	}
+
+	return key, customer.ID, nil
+}
+
+// LinkMerchant lets the merchant act for the customer with its API keys.
+func (s *Service) LinkMerchant(ctx context.Context, customerID uuid.UUID, merchantLogin string) error {
+	merchant, err := s.repo.GetUserByLogin(ctx, merchantLogin)
+	if err != nil {
+		return err
+	}
+	if merchant.Role != model.RoleMerchant {
+		return model.ErrNotMerchant
+	}
+
+	return s.repo.LinkMerchant(ctx, merchant.ID, customerID)
+}
+
+// UnlinkMerchant withdraws the customer's consent. The merchant's keys stop
+// working for the customer immediately.
+func (s *Service) UnlinkMerchant(ctx context.Context, customerID uuid.UUID, merchantLogin string) error {
+	merchant, err := s.repo.GetUserByLogin(ctx, merchantLogin)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.UnlinkMerchant(ctx, merchant.ID, customerID)
+}
+
+func (s *Service) GetLinkedMerchants(ctx context.Context, customerID uuid.UUID) ([]model.LinkedMerchant, error) {
+	links, err := s.repo.GetCustomerMerchants(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	merchants := make([]model.LinkedMerchant, 0, len(links))
+	for _, link := range links {
+		if link.Merchant == nil {
+			continue
+		}
+		merchants = append(merchants, model.LinkedMerchant{Merchant: link.Merchant.Login, LinkedAt: link.CreatedAt})
+	}
+
+	return merchants, nil
+}
+
+func validScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+
+	for _, scope := range scopes {
+		known := false
+		for _, k := range model.KnownScopes {
+			if scope == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+
+	return true
+}
+
+func dedupScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	res := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			res = append(res, scope)
+		}
+	}
+
+	return res
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.WithMessage(err, "error occurred while generating random string")
+	}
+
+	return encode(buf), nil
+}
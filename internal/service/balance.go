@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (s *Service) GetBalance(ctx context.Context, userID uuid.UUID) (*model.Balance, error) {
+	return s.repo.GetBalance(ctx, userID)
+}
+
+func (s *Service) Withdraw(ctx context.Context, userID uuid.UUID, req model.WithdrawRequest) error {
+	if !isValidLuhn(req.Order) {
+		return model.ErrInvalidOrderNumber
+	}
+	if req.Sum <= 0 {
+		return model.ErrInvalidWithdrawSum
+	}
+	if math.Round(req.Sum*100)/100 != req.Sum {
+		return model.ErrWithdrawSumScale
+	}
+
+	return s.repo.Withdraw(ctx, &model.Withdrawal{
+		UserID:      userID,
+		OrderNumber: req.Order,
+		Sum:         req.Sum,
+	})
+}
+
+func (s *Service) GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.Withdrawal, error) {
+	return s.repo.GetUserWithdrawals(ctx, userID)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+const (
+	loginKeyPrefix = "login:"
+	ipKeyPrefix    = "ip:"
+)
+
+// loginAttempts are the counters acquired for one login attempt, the login
+// counter first.
+type loginAttempts []*model.LoginAttempt
+
+// acquireLoginAttempts counts the attempt for the login and the IP before the
+// credentials are checked, so parallel guesses can't exceed the limits. It
+// returns model.LoginLockedError while either of them is locked.
+func (s *Service) acquireLoginAttempts(ctx context.Context, login, ip string) (loginAttempts, error) {
+	keys := []string{loginKeyPrefix + login}
+	if ip != "" {
+		keys = append(keys, ipKeyPrefix+ip)
+	}
+
+	attempts := make(loginAttempts, 0, len(keys))
+	for _, key := range keys {
+		attempt, err := s.repo.AcquireLoginAttempt(ctx, key, lockoutPolicy(key))
+		if err != nil {
+			s.abandonLoginAttempts(ctx, attempts)
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, nil
+}
+
+// recordLoginFailure records a lockout event for every key the failed
+// attempt has locked.
+func (s *Service) recordLoginFailure(ctx context.Context, attempts loginAttempts, login, ip string) error {
+	for _, attempt := range attempts {
+		if !lockoutPolicy(attempt.Key).Locks(attempt.Failures) {
+			continue
+		}
+
+		err := s.repo.AddAuthEvent(ctx, &model.AuthEvent{
+			Key:         attempt.Key,
+			Login:       login,
+			IP:          ip,
+			Event:       model.AuthEventLockout,
+			Failures:    attempt.Failures,
+			LockedUntil: attempt.LockedUntil,
+		})
+		if err != nil {
+			return err
+		}
+		util.GetLogger().Warnf("%s locked until %s after %d failed logins",
+			attempt.Key, attempt.LockedUntil.Format(time.RFC3339), attempt.Failures)
+	}
+
+	return nil
+}
+
+// releaseLoginAttempts takes back the acquired attempts when the credentials
+// were valid.
+func (s *Service) releaseLoginAttempts(ctx context.Context, attempts loginAttempts) error {
+	for _, attempt := range attempts {
+		if err := s.repo.ReleaseLoginAttempt(ctx, attempt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// abandonLoginAttempts releases the acquired attempts when the check failed
+// on the server side, so such failures don't count against the user. The
+// release error is only logged since the check error is the one returned.
+func (s *Service) abandonLoginAttempts(ctx context.Context, attempts loginAttempts) {
+	if err := s.releaseLoginAttempts(ctx, attempts); err != nil {
+		util.GetLogger().Errorf("failed to release login attempts: %v", err)
+	}
+}
+
+// resetLoginFailures clears the failure counter of the login after a
+// successful login. The IP counter is only released, so a valid account
+// can't be used to reset it.
+func (s *Service) resetLoginFailures(ctx context.Context, attempts loginAttempts, login, ip string) error {
+	if len(attempts) == 0 {
+		return nil
+	}
+	if err := s.releaseLoginAttempts(ctx, attempts[1:]); err != nil {
+		return err
+	}
+
+	attempt := attempts[0]
+	var event *model.AuthEvent
+	if attempt.Failures > lockoutPolicy(attempt.Key).Threshold {
+		event = &model.AuthEvent{
+			Key:      attempt.Key,
+			Login:    login,
+			IP:       ip,
+			Event:    model.AuthEventUnlock,
+			Failures: attempt.Failures - 1,
+		}
+	}
+
+	return s.repo.ResetLoginAttempts(ctx, attempt.Key, event)
+}
+
+// PruneLoginAttempts deletes the failure counters that have expired.
+func (s *Service) PruneLoginAttempts(ctx context.Context) error {
+	window := time.Duration(util.GetConfig().Auth.Lockout.Window) * time.Second
+	pruned, err := s.repo.PruneLoginAttempts(ctx, window)
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		util.GetLogger().Debugf("pruned %d expired login attempt counters", pruned)
+	}
+
+	return nil
+}
+
+// lockoutPolicy returns the limits of the login or IP key.
+func lockoutPolicy(key string) model.LockoutPolicy {
+	cfg := util.GetConfig().Auth.Lockout
+	policy := model.LockoutPolicy{
+		Threshold: cfg.Threshold,
+		BaseDelay: time.Duration(cfg.BaseDelay) * time.Second,
+		MaxDelay:  time.Duration(cfg.MaxDelay) * time.Second,
+		Window:    time.Duration(cfg.Window) * time.Second,
+	}
+	if strings.HasPrefix(key, ipKeyPrefix) {
+		policy.Threshold = cfg.IPThreshold
+	}
+
+	return policy
+}
@@ -0,0 +1,30 @@
+package service
+
+// isValidLuhn reports whether number is a non-empty string of digits with a
+// valid Luhn checksum.
+func isValidLuhn(number string) bool {
+	if number == "" {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		ch := number[i]
+		if ch < '0' || ch > '9' {
+			return false
+		}
+
+		digit := int(ch - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+
+	return sum%10 == 0
+}
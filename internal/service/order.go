@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (s *Service) UploadOrder(ctx context.Context, userID uuid.UUID, number string) error {
+	if !isValidLuhn(number) {
+		return model.ErrInvalidOrderNumber
+	}
+
+	err := s.repo.CreateOrder(ctx, &model.Order{
+		Number: number,
+		UserID: userID,
+		Status: model.OrderStatusNew,
+	})
+	if !errors.Is(err, model.ErrOrderExists) {
+		return err
+	}
+
+	existing, err := s.repo.GetOrder(ctx, number)
+	if err != nil {
+		return err
+	}
+	if existing.UserID != userID {
+		return model.ErrOrderUploadedByAnother
+	}
+
+	return model.ErrOrderAlreadyUploaded
+}
+
+func (s *Service) GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.Order, error) {
+	return s.repo.GetUserOrders(ctx, userID)
+}
+
+const reviewOrdersLimit = 100
+
+// GetOrdersForReview returns the orders the accrual worker gave up polling
+// because they stayed pending for too long.
+func (s *Service) GetOrdersForReview(ctx context.Context) ([]model.AccrualJob, error) {
+	return s.repo.GetFlaggedAccrualJobs(ctx, reviewOrdersLimit)
+}
+
+// ApplyAccrual records an order update pushed by the accrual system. Orders
+// that are already final are left intact, so repeated deliveries of the same
+// update are harmless. Once the order is final its polling job is removed,
+// including a job flagged for review.
+func (s *Service) ApplyAccrual(ctx context.Context, update model.AccrualOrder) error {
+	status, ok := update.Status.OrderStatus()
+	if update.Order == "" || !ok || (update.Accrual != nil && *update.Accrual < 0) {
+		return model.ErrInvalidAccrualUpdate
+	}
+
+	order, err := s.repo.GetOrder(ctx, update.Order)
+	if err != nil {
+		return err
+	}
+	if isFinalStatus(order.Status) {
+		return s.repo.FinishAccrualJob(ctx, order.Number)
+	}
+
+	switch {
+	case status == model.OrderStatusProcessed:
+		var amount float64
+		if update.Accrual != nil {
+			amount = *update.Accrual
+		}
+		err = s.repo.CompleteOrder(ctx, order.Number, amount)
+	case status != order.Status:
+		err = s.repo.UpdateOrderStatus(ctx, order.Number, status)
+	}
+	if err != nil || !isFinalStatus(status) {
+		return err
+	}
+
+	return s.repo.FinishAccrualJob(ctx, order.Number)
+}
+
+func isFinalStatus(status model.OrderStatus) bool {
+	return status == model.OrderStatusProcessed || status == model.OrderStatusInvalid
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// denyList holds the breached passwords from PasswordPolicy.DenyListFile. It
+// is loaded on first use.
+var denyList struct {
+	once      sync.Once
+	passwords map[string]struct{}
+	err       error
+}
+
+// ChangePassword replaces the password after checking the current one. All
+// sessions of the user are revoked and previously issued access tokens stop
+// being accepted.
+func (s *Service) ChangePassword(ctx context.Context, userID uuid.UUID, req model.ChangePasswordRequest) error {
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		return model.ErrInvalidAuthRequest
+	}
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword))
+	if err != nil {
+		return model.ErrWrongPassword
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	version, err := s.repo.UpdatePassword(ctx, userID, hash)
+	if err != nil {
+		return err
+	}
+	util.GetLogger().Infof("password of user %s changed, token version %d", userID, version)
+
+	return nil
+}
+
+// hashPassword checks the password against the policy and hashes it.
+func hashPassword(password string) (string, error) {
+	if err := validatePassword(password); err != nil {
+		return "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.WithMessage(err, "error occurred while hashing password")
+	}
+
+	return string(hash), nil
+}
+
+func validatePassword(password string) error {
+	policy := util.GetConfig().Auth.PasswordPolicy
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return model.ErrPasswordTooShort
+	}
+	if len(password) > policy.MaxLength {
+		return model.ErrPasswordTooLong
+	}
+
+	denyList.once.Do(func() {
+		denyList.passwords, denyList.err = loadDenyList(policy.DenyListFile)
+	})
+	if denyList.err != nil {
+		return denyList.err
+	}
+	if _, denied := denyList.passwords[password]; denied {
+		return model.ErrPasswordBreached
+	}
+
+	return nil
+}
+
+func loadDenyList(path string) (map[string]struct{}, error) {
+	passwords := make(map[string]struct{})
+	if path == "" {
+		return passwords, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while opening password deny list")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			passwords[line] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, errors.WithMessage(err, "error occurred while reading password deny list")
+	}
+	util.GetLogger().Infof("password deny list loaded, %d entries", len(passwords))
+
+	return passwords, nil
+}
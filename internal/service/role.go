@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+const authEventsLimit = 100
+
+func (s *Service) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
+	return s.repo.GetUserByLogin(ctx, login)
+}
+
+// SetUserRole changes the role of the account. Access tokens issued with the
+// previous role stop being accepted; the owner picks up the new role with
+// the next token refresh.
+func (s *Service) SetUserRole(ctx context.Context, login string, role model.Role) error {
+	if !role.Valid() {
+		return model.ErrInvalidRole
+	}
+
+	user, err := s.repo.GetUserByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+	if user.Role == role {
+		return nil
+	}
+
+	if err = s.repo.UpdateUserRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+	util.GetLogger().Infof("role of user %s changed from %s to %s", login, user.Role, role)
+
+	return nil
+}
+
+// GetAuthEvents returns the latest lockouts and unlocks of the login.
+func (s *Service) GetAuthEvents(ctx context.Context, login string) ([]model.AuthEvent, error) {
+	return s.repo.GetAuthEvents(ctx, login, authEventsLimit)
+}
+
+// PromoteAdmins grants the admin role to the existing accounts among the
+// logins. Registration never grants it, so a login that has no account yet
+// can't be claimed by whoever registers it first; it is skipped until the
+// next start.
+func (s *Service) PromoteAdmins(ctx context.Context, logins []string) error {
+	for _, login := range logins {
+		err := s.SetUserRole(ctx, login, model.RoleAdmin)
+		if errors.Is(err, model.ErrNotFound) {
+			util.GetLogger().Warnf("admin login %s has no account, not promoted", login)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
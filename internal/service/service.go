@@ -1,12 +1,54 @@
 package service
 
-import "github.com/ypxd99/yandex-diplom-56/internal/repository"
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/internal/repository"
+)
 
 type Service struct {
 	repo repository.GophermartRepo
 }
 
 type GophermartService interface {
+	Register(ctx context.Context, login, password string) (uuid.UUID, error)
+	Login(ctx context.Context, login, password, ip string) (uuid.UUID, error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, req model.ChangePasswordRequest) error
+
+	EnrollTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) (*model.RecoveryCodes, error)
+	VerifySecondFactor(ctx context.Context, userID uuid.UUID, req model.TwoFactorLoginRequest, ip string) error
+
+	GetUserByLogin(ctx context.Context, login string) (*model.User, error)
+	SetUserRole(ctx context.Context, login string, role model.Role) error
+	GetAuthEvents(ctx context.Context, login string) ([]model.AuthEvent, error)
+	PromoteAdmins(ctx context.Context, logins []string) error
+	PruneLoginAttempts(ctx context.Context) error
+
+	StartSession(ctx context.Context, userID uuid.UUID, meta model.SessionMeta) (*model.IssuedSession, error)
+	RefreshSession(ctx context.Context, refreshToken string, meta model.SessionMeta) (*model.IssuedSession, error)
+	GetSessions(ctx context.Context, userID, currentID uuid.UUID) ([]model.Session, error)
+	RevokeSession(ctx context.Context, userID, id uuid.UUID) error
+	IsSessionActive(ctx context.Context, userID, sessionID uuid.UUID, tokenVersion int) (bool, error)
+
+	CreateAPIKey(ctx context.Context, merchantID uuid.UUID, req model.APIKeyRequest) (*model.IssuedAPIKey, error)
+	GetAPIKeys(ctx context.Context, merchantID uuid.UUID) ([]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, merchantID, id uuid.UUID) error
+	AuthenticateAPIKey(ctx context.Context, rawKey, customerLogin string) (*model.APIKey, uuid.UUID, error)
+	LinkMerchant(ctx context.Context, customerID uuid.UUID, merchantLogin string) error
+	UnlinkMerchant(ctx context.Context, customerID uuid.UUID, merchantLogin string) error
+	GetLinkedMerchants(ctx context.Context, customerID uuid.UUID) ([]model.LinkedMerchant, error)
+
+	UploadOrder(ctx context.Context, userID uuid.UUID, number string) error
+	GetUserOrders(ctx context.Context, userID uuid.UUID) ([]model.Order, error)
+	GetOrdersForReview(ctx context.Context) ([]model.AccrualJob, error)
+	ApplyAccrual(ctx context.Context, update model.AccrualOrder) error
+
+	GetBalance(ctx context.Context, userID uuid.UUID) (*model.Balance, error)
+	Withdraw(ctx context.Context, userID uuid.UUID, req model.WithdrawRequest) error
+	GetUserWithdrawals(ctx context.Context, userID uuid.UUID) ([]model.Withdrawal, error)
 }
 
 func InitService(repo repository.GophermartRepo) *Service {
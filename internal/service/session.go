@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+const (
+	refreshTokenBytes = 32
+
+	// maxUserAgentLength bounds the stored User-Agent and device name.
+	maxUserAgentLength = 512
+)
+
+// StartSession opens a new refresh token family for the user, recording the
+// client it was opened from.
+func (s *Service) StartSession(ctx context.Context, userID uuid.UUID, meta model.SessionMeta) (*model.IssuedSession, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, refresh, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	meta = normalizeSessionMeta(meta)
+	session := &model.Session{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Device:    meta.Device,
+		UserAgent: meta.UserAgent,
+		IP:        meta.IP,
+	}
+	if err = s.repo.CreateSession(ctx, session, refresh); err != nil {
+		return nil, err
+	}
+
+	return &model.IssuedSession{
+		UserID:           userID,
+		SessionID:        session.ID,
+		Role:             user.Role,
+		TokenVersion:     user.TokenVersion,
+		RefreshToken:     token,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// RefreshSession exchanges a refresh token for a new one in the same family
+// and updates the client the session was last seen from.
+func (s *Service) RefreshSession(ctx context.Context, refreshToken string, meta model.SessionMeta) (*model.IssuedSession, error) {
+	if refreshToken == "" {
+		return nil, model.ErrInvalidRefreshToken
+	}
+
+	token, next, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.RotateRefreshToken(ctx, hashToken(refreshToken), next)
+	if errors.Is(err, model.ErrRefreshTokenReused) {
+		util.GetLogger().Warnf("refresh token reuse detected, session %s revoked", next.SessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.repo.TouchSession(ctx, next.SessionID, normalizeSessionMeta(meta)); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUser(ctx, next.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.IssuedSession{
+		UserID:           next.UserID,
+		SessionID:        next.SessionID,
+		Role:             user.Role,
+		TokenVersion:     user.TokenVersion,
+		RefreshToken:     token,
+		RefreshExpiresAt: next.ExpiresAt,
+	}, nil
+}
+
+// GetSessions lists the active sessions of the user and marks the one the
+// request was made from.
+func (s *Service) GetSessions(ctx context.Context, userID, currentID uuid.UUID) ([]model.Session, error) {
+	sessions, err := s.repo.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs the user out of one of their sessions. The refresh
+// tokens and access tokens of the session stop being accepted immediately.
+func (s *Service) RevokeSession(ctx context.Context, userID, id uuid.UUID) error {
+	return s.repo.RevokeUserSession(ctx, userID, id)
+}
+
+// IsSessionActive reports whether the session of an access token has not
+// been revoked and the token was issued after the last password change.
+func (s *Service) IsSessionActive(ctx context.Context, userID, sessionID uuid.UUID, tokenVersion int) (bool, error) {
+	session, err := s.repo.GetSession(ctx, sessionID)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if session.RevokedAt != nil || session.UserID != userID {
+		return false, nil
+	}
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return user.TokenVersion == tokenVersion, nil
+}
+
+func newRefreshToken() (string, *model.RefreshToken, error) {
+	buf := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, errors.WithMessage(err, "error occurred while generating refresh token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	ttl := time.Duration(util.GetConfig().Auth.RefreshTokenTTL) * time.Second
+	return token, &model.RefreshToken{
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+func normalizeSessionMeta(meta model.SessionMeta) model.SessionMeta {
+	meta.Device = truncate(strings.TrimSpace(meta.Device), maxUserAgentLength)
+	meta.UserAgent = truncate(meta.UserAgent, maxUserAgentLength)
+
+	return meta
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
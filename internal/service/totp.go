@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/internal/totp"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+const (
+	recoveryCodesCount = 10
+	recoveryCodeBytes  = 5
+)
+
+// EnrollTOTP generates a new pending secret. It takes effect only after
+// ConfirmTOTP, so enrolling again before that simply replaces it.
+func (s *Service) EnrollTOTP(ctx context.Context, userID uuid.UUID) (*model.TOTPEnrollment, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, model.ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.repo.SetTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &model.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(util.GetConfig().Auth.TOTPIssuer, user.Login, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA once the user proves the authenticator app works
+// and returns fresh recovery codes, which are shown only this once.
+func (s *Service) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) (*model.RecoveryCodes, error) {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, model.ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, model.ErrTOTPNotEnrolled
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, model.ErrInvalidTOTPCode
+	}
+
+	plain := make([]string, 0, recoveryCodesCount)
+	codes := make([]model.RecoveryCode, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		plain = append(plain, code)
+		codes = append(codes, model.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err = s.repo.EnableTOTP(ctx, userID, step, codes); err != nil {
+		return nil, err
+	}
+	util.GetLogger().Infof("two-factor authentication enabled for user %s", userID)
+
+	return &model.RecoveryCodes{Codes: plain}, nil
+}
+
+// VerifySecondFactor completes a two-step login with a TOTP or a recovery
+// code. Wrong codes count as failed logins for the lockout.
+func (s *Service) VerifySecondFactor(ctx context.Context, userID uuid.UUID, req model.TwoFactorLoginRequest, ip string) error {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	attempts, err := s.acquireLoginAttempts(ctx, user.Login, ip)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.checkSecondFactor(ctx, user, req)
+	if err != nil {
+		s.abandonLoginAttempts(ctx, attempts)
+		return err
+	}
+	if !ok {
+		if err = s.recordLoginFailure(ctx, attempts, user.Login, ip); err != nil {
+			return err
+		}
+		return model.ErrInvalidTOTPCode
+	}
+
+	return s.resetLoginFailures(ctx, attempts, user.Login, ip)
+}
+
+func (s *Service) checkSecondFactor(ctx context.Context, user *model.User, req model.TwoFactorLoginRequest) (bool, error) {
+	if !user.TOTPEnabled {
+		return false, nil
+	}
+
+	if req.RecoveryCode != "" {
+		return s.repo.UseRecoveryCode(ctx, user.ID, hashToken(normalizeRecoveryCode(req.RecoveryCode)))
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return s.repo.UseTOTPStep(ctx, user.ID, step)
+}
+
+// newRecoveryCode returns a code formatted as xxxx-xxxx for readability.
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, recoveryCodeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.WithMessage(err, "error occurred while generating recovery code")
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+
+	return code[:4] + "-" + code[4:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
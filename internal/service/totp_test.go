@@ -0,0 +1,45 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ypxd99/yandex-diplom-56/internal/mocks"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/internal/service"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("CONFIG_PATH", "../../configuration/config.yaml")
+	os.Setenv("LOG_LEVEL", "error")
+	util.InitLogger(util.GetConfig().Logger)
+
+	os.Exit(m.Run())
+}
+
+func TestVerifySecondFactorReleasesAttemptsOnError(t *testing.T) {
+	ctx := context.Background()
+	user := &model.User{ID: uuid.New(), Login: "user", TOTPEnabled: true}
+	loginAttempt := &model.LoginAttempt{Key: "login:" + user.Login, Failures: 1}
+	ipAttempt := &model.LoginAttempt{Key: "ip:127.0.0.1", Failures: 1}
+	repoErr := errors.New("connection reset")
+
+	repo := new(mocks.MockGophermartRepo)
+	repo.On("GetUser", ctx, user.ID).Return(user, nil)
+	repo.On("AcquireLoginAttempt", ctx, loginAttempt.Key, mock.Anything).Return(loginAttempt, nil)
+	repo.On("AcquireLoginAttempt", ctx, ipAttempt.Key, mock.Anything).Return(ipAttempt, nil)
+	repo.On("UseRecoveryCode", ctx, user.ID, mock.Anything).Return(false, repoErr)
+	repo.On("ReleaseLoginAttempt", ctx, loginAttempt).Return(nil)
+	repo.On("ReleaseLoginAttempt", ctx, ipAttempt).Return(nil)
+
+	err := service.InitService(repo).VerifySecondFactor(ctx, user.ID, model.TwoFactorLoginRequest{RecoveryCode: "abcd-efgh"}, "127.0.0.1")
+	assert.ErrorIs(t, err, repoErr)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "AddAuthEvent", mock.Anything, mock.Anything)
+}
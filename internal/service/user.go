@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is compared against when the login is unknown, so a missing
+// account takes as long to reject as a wrong password.
+var dummyHash struct {
+	once sync.Once
+	hash []byte
+}
+
+func (s *Service) Register(ctx context.Context, login, password string) (uuid.UUID, error) {
+	if login == "" || password == "" {
+		return uuid.Nil, model.ErrInvalidAuthRequest
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user := &model.User{
+		ID:           uuid.New(),
+		Login:        login,
+		PasswordHash: hash,
+		Role:         model.RoleUser,
+	}
+	err = s.repo.CreateUser(ctx, user)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return user.ID, nil
+}
+
+// Login checks the credentials. Attempts are counted per login and per client
+// IP before the check, and both are locked out after too many failed ones. For accounts
+// with 2FA enabled it returns the user ID with model.ErrTwoFactorRequired,
+// and the login is completed by VerifySecondFactor.
+func (s *Service) Login(ctx context.Context, login, password, ip string) (uuid.UUID, error) {
+	if login == "" || password == "" {
+		return uuid.Nil, model.ErrInvalidAuthRequest
+	}
+
+	attempts, err := s.acquireLoginAttempts(ctx, login, ip)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user, err := s.checkCredentials(ctx, login, password)
+	if errors.Is(err, model.ErrInvalidCredentials) {
+		if err := s.recordLoginFailure(ctx, attempts, login, ip); err != nil {
+			return uuid.Nil, err
+		}
+		return uuid.Nil, model.ErrInvalidCredentials
+	}
+	if err != nil {
+		s.abandonLoginAttempts(ctx, attempts)
+		return uuid.Nil, err
+	}
+
+	if user.TOTPEnabled {
+		if err = s.releaseLoginAttempts(ctx, attempts); err != nil {
+			return uuid.Nil, err
+		}
+		return user.ID, model.ErrTwoFactorRequired
+	}
+
+	if err = s.resetLoginFailures(ctx, attempts, login, ip); err != nil {
+		return uuid.Nil, err
+	}
+
+	return user.ID, nil
+}
+
+func (s *Service) checkCredentials(ctx context.Context, login, password string) (*model.User, error) {
+	user, err := s.repo.GetUserByLogin(ctx, login)
+	if errors.Is(err, model.ErrNotFound) {
+		dummyHash.once.Do(func() {
+			dummyHash.hash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+		})
+		_ = bcrypt.CompareHashAndPassword(dummyHash.hash, []byte(password))
+		return nil, model.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return nil, model.ErrInvalidCredentials
+	}
+
+	return user, nil
+}
@@ -0,0 +1,54 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeRFC6238(t *testing.T) {
+	// SHA1 test vectors from RFC 6238, appendix B, truncated to 6 digits.
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+	for _, tt := range tests {
+		code, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code, tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Now()
+
+	code, err := Code(secret, Step(now)-1)
+	require.NoError(t, err)
+	step, ok := Validate(secret, code, now)
+	assert.True(t, ok, "previous step is accepted")
+	assert.Equal(t, Step(now)-1, step)
+
+	code, err = Code(secret, Step(now)-3)
+	require.NoError(t, err)
+	_, ok = Validate(secret, code, now)
+	assert.False(t, ok, "old codes are rejected")
+
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Gophermart", "alice", "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, "otpauth://totp/Gophermart:alice?algorithm=SHA1&digits=6&issuer=Gophermart&period=30&secret=JBSWY3DPEHPK3PXP", uri)
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+// accrualCallback applies an order update pushed by the accrual system. The
+// signature has been checked by AccrualSignatureMiddleware. Repeated
+// deliveries are answered with 200 as well.
+func (h *Handler) accrualCallback(c *gin.Context) {
+	var update model.AccrualOrder
+	if err := c.ShouldBindJSON(&update); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	err := h.service.ApplyAccrual(c.Request.Context(), update)
+	switch {
+	case errors.Is(err, model.ErrInvalidAccrualUpdate):
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	case errors.Is(err, model.ErrNotFound):
+		response(c, http.StatusNotFound, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+// lookupUser resolves the :login path parameter of the admin routes. It
+// writes the error response itself and reports whether the user was found.
+func (h *Handler) lookupUser(c *gin.Context) (*model.User, bool) {
+	user, err := h.service.GetUserByLogin(c.Request.Context(), c.Param("login"))
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		response(c, http.StatusNotFound, err, nil)
+		return nil, false
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (h *Handler) adminGetUser(c *gin.Context) {
+	user, ok := h.lookupUser(c)
+	if !ok {
+		return
+	}
+
+	response(c, http.StatusOK, nil, user)
+}
+
+func (h *Handler) adminGetUserOrders(c *gin.Context) {
+	user, ok := h.lookupUser(c)
+	if !ok {
+		return
+	}
+
+	orders, err := h.service.GetUserOrders(c.Request.Context(), user.ID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(orders) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, orders)
+}
+
+func (h *Handler) adminGetUserBalance(c *gin.Context) {
+	user, ok := h.lookupUser(c)
+	if !ok {
+		return
+	}
+
+	balance, err := h.service.GetBalance(c.Request.Context(), user.ID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, balance)
+}
+
+func (h *Handler) adminGetUserWithdrawals(c *gin.Context) {
+	user, ok := h.lookupUser(c)
+	if !ok {
+		return
+	}
+
+	withdrawals, err := h.service.GetUserWithdrawals(c.Request.Context(), user.ID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(withdrawals) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, withdrawals)
+}
+
+func (h *Handler) adminGetAuthEvents(c *gin.Context) {
+	events, err := h.service.GetAuthEvents(c.Request.Context(), c.Param("login"))
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(events) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, events)
+}
+
+// adminGetOrdersForReview lists the orders that stayed pending at the
+// accrual system for too long and are no longer polled.
+func (h *Handler) adminGetOrdersForReview(c *gin.Context) {
+	jobs, err := h.service.GetOrdersForReview(c.Request.Context())
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(jobs) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, jobs)
+}
+
+func (h *Handler) adminSetUserRole(c *gin.Context) {
+	var req model.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	err := h.service.SetUserRole(c.Request.Context(), c.Param("login"), req.Role)
+	switch {
+	case errors.Is(err, model.ErrInvalidRole):
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	case errors.Is(err, model.ErrNotFound):
+		response(c, http.StatusNotFound, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (h *Handler) createAPIKey(c *gin.Context) {
+	merchantID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	var req model.APIKeyRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(c.Request.Context(), merchantID, req)
+	switch {
+	case errors.Is(err, model.ErrInvalidAPIKeyRequest):
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	case errors.Is(err, model.ErrNotMerchant):
+		response(c, http.StatusForbidden, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusCreated, nil, key)
+}
+
+func (h *Handler) getAPIKeys(c *gin.Context) {
+	merchantID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	keys, err := h.service.GetAPIKeys(c.Request.Context(), merchantID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(keys) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, keys)
+}
+
+func (h *Handler) revokeAPIKey(c *gin.Context) {
+	merchantID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	err = h.service.RevokeAPIKey(c.Request.Context(), merchantID, id)
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		response(c, http.StatusNotFound, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) linkMerchant(c *gin.Context) {
+	customerID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	var req model.MerchantRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	err = h.service.LinkMerchant(c.Request.Context(), customerID, req.Merchant)
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, model.ErrNotFound):
+		response(c, http.StatusNotFound, err, nil)
+	case errors.Is(err, model.ErrNotMerchant):
+		response(c, http.StatusUnprocessableEntity, err, nil)
+	default:
+		response(c, http.StatusInternalServerError, err, nil)
+	}
+}
+
+func (h *Handler) getLinkedMerchants(c *gin.Context) {
+	customerID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	merchants, err := h.service.GetLinkedMerchants(c.Request.Context(), customerID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(merchants) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, merchants)
+}
+
+func (h *Handler) unlinkMerchant(c *gin.Context) {
+	customerID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	err = h.service.UnlinkMerchant(c.Request.Context(), customerID, c.Param("login"))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, model.ErrNotFound):
+		response(c, http.StatusNotFound, err, nil)
+	default:
+		response(c, http.StatusInternalServerError, err, nil)
+	}
+}
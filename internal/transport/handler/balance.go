@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (h *Handler) getBalance(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	balance, err := h.service.GetBalance(c.Request.Context(), userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, balance)
+}
+
+func (h *Handler) withdraw(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	var req model.WithdrawRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	err = h.service.Withdraw(c.Request.Context(), userID, req)
+	switch {
+	case err == nil:
+		c.Status(http.StatusOK)
+	case errors.Is(err, model.ErrInsufficientFunds):
+		response(c, http.StatusPaymentRequired, err, nil)
+	case errors.Is(err, model.ErrInvalidOrderNumber),
+		errors.Is(err, model.ErrWithdrawalExists),
+		errors.Is(err, model.ErrWithdrawSumScale):
+		response(c, http.StatusUnprocessableEntity, err, nil)
+	case errors.Is(err, model.ErrInvalidWithdrawSum):
+		response(c, http.StatusBadRequest, err, nil)
+	default:
+		response(c, http.StatusInternalServerError, err, nil)
+	}
+}
+
+func (h *Handler) getUserWithdrawals(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	withdrawals, err := h.service.GetUserWithdrawals(c.Request.Context(), userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(withdrawals) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, withdrawals)
+}
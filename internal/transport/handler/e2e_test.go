@@ -0,0 +1,937 @@
+package handler_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ypxd99/yandex-diplom-56/internal/accrualstub"
+	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/internal/repository/memory"
+	"github.com/ypxd99/yandex-diplom-56/internal/service"
+	"github.com/ypxd99/yandex-diplom-56/internal/totp"
+	"github.com/ypxd99/yandex-diplom-56/internal/transport/handler"
+	"github.com/ypxd99/yandex-diplom-56/internal/worker"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+const (
+	callbackSecret = "callback-secret"
+	adminPassword  = "admin-secret"
+)
+
+var (
+	gophermartURL string
+	store         *memory.Memory
+	accrual       *accrualstub.Stub
+	adminLogin    = "admin-" + time.Now().Format(time.RFC3339Nano)
+	// lateAdminLogin is listed in AdminLogins but registered after startup.
+	lateAdminLogin = "late-" + adminLogin
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	accrual = accrualstub.NewStub(&accrualstub.Script{})
+	accrualRouter := gin.New()
+	accrual.InitRoutes(accrualRouter)
+	accrualSrv := httptest.NewServer(accrualRouter)
+
+	os.Setenv("CONFIG_PATH", "../../../configuration/config.yaml")
+	os.Setenv("LOG_LEVEL", "error")
+	os.Setenv("ACCRUAL_SYSTEM_ADDRESS", accrualSrv.URL)
+	os.Setenv("ACCRUAL_POLL_INTERVAL", "1")
+	// Every test client connects from 127.0.0.1, so only the per-login
+	// lockout is exercised.
+	os.Setenv("AUTH_LOCKOUT_IP_THRESHOLD", "1000")
+	os.Setenv("AUTH_ADMIN_LOGINS", adminLogin+","+lateAdminLogin)
+	os.Setenv("ACCRUAL_CALLBACK_SECRET", callbackSecret)
+
+	denyList, err := os.CreateTemp("", "deny-list-*.txt")
+	if err != nil {
+		panic(err)
+	}
+	if _, err = denyList.WriteString("password\nqwerty123\n"); err != nil {
+		panic(err)
+	}
+	denyList.Close()
+	os.Setenv("AUTH_PASSWORD_DENY_LIST", denyList.Name())
+	os.Unsetenv("DATABASE_URI")
+	util.InitLogger(util.GetConfig().Logger)
+
+	store = memory.New()
+	pool := worker.NewPool(store)
+	pool.Start(context.Background())
+
+	svc := service.InitService(store)
+	if _, err = svc.Register(context.Background(), adminLogin, adminPassword); err != nil {
+		panic(err)
+	}
+	if err = svc.PromoteAdmins(context.Background(), util.GetConfig().Auth.AdminLogins); err != nil {
+		panic(err)
+	}
+
+	router := gin.New()
+	handler.InitHandler(svc).InitRoutes(router)
+	srv := httptest.NewServer(router)
+	gophermartURL = srv.URL
+
+	code := m.Run()
+
+	srv.Close()
+	pool.Stop()
+	accrualSrv.Close()
+	os.Remove(denyList.Name())
+	os.Exit(code)
+}
+
+type client struct {
+	t    *testing.T
+	http *http.Client
+}
+
+func newClient(t *testing.T) *client {
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+
+	return &client{t: t, http: &http.Client{Jar: jar}}
+}
+
+func (c *client) do(method, path, contentType string, body []byte, headers map[string]string) *http.Response {
+	c.t.Helper()
+
+	req, err := http.NewRequest(method, gophermartURL+path, bytes.NewReader(body))
+	require.NoError(c.t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.http.Jar != nil {
+		// Like a browser app, echo the CSRF cookie on every request.
+		for _, cookie := range c.http.Jar.Cookies(req.URL) {
+			if cookie.Name == middleware.CSRFCookie {
+				req.Header.Set(middleware.CSRFHeader, cookie.Value)
+			}
+		}
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.http.Do(req)
+	require.NoError(c.t, err)
+	c.t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func (c *client) postJSON(path string, body interface{}) *http.Response {
+	c.t.Helper()
+
+	data, err := json.Marshal(body)
+	require.NoError(c.t, err)
+
+	return c.do(http.MethodPost, path, "application/json", data, nil)
+}
+
+func (c *client) postText(path, body string) *http.Response {
+	c.t.Helper()
+	return c.do(http.MethodPost, path, "text/plain", []byte(body), nil)
+}
+
+func (c *client) get(path string) *http.Response {
+	c.t.Helper()
+	return c.do(http.MethodGet, path, "", nil, nil)
+}
+
+func (c *client) register(login, password string) {
+	c.t.Helper()
+
+	resp := c.postJSON("/api/user/register", model.AuthRequest{Login: login, Password: password})
+	require.Equal(c.t, http.StatusOK, resp.StatusCode)
+}
+
+// newAdminClient logs in as the admin account promoted at startup.
+func newAdminClient(t *testing.T) *client {
+	c := newClient(t)
+	resp := c.postJSON("/api/user/login", model.AuthRequest{Login: adminLogin, Password: adminPassword})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	return c
+}
+
+func (c *client) setRole(login string, role model.Role) int {
+	c.t.Helper()
+
+	data, err := json.Marshal(model.RoleRequest{Role: role})
+	require.NoError(c.t, err)
+
+	return c.do(http.MethodPut, "/api/admin/users/"+login+"/role", "application/json", data, nil).StatusCode
+}
+
+func decode(t *testing.T, resp *http.Response, dst interface{}) {
+	t.Helper()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(dst))
+}
+
+func uniqueLogin(t *testing.T) string {
+	return t.Name() + "-" + time.Now().Format(time.RFC3339Nano)
+}
+
+// newOrderNumber returns a random order number with a valid Luhn checksum.
+func newOrderNumber() string {
+	digits := make([]byte, 12)
+	for i := range digits {
+		digits[i] = byte('0' + rand.Intn(10))
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i -= 2 {
+		d := int(digits[i]-'0') * 2
+		if d > 9 {
+			d -= 9
+		}
+		sum += d
+		if i > 0 {
+			sum += int(digits[i-1] - '0')
+		}
+	}
+
+	return string(digits) + string(byte('0'+(10-sum%10)%10))
+}
+
+func withBadChecksum(number string) string {
+	last := number[len(number)-1]
+	return number[:len(number)-1] + string(byte('0'+(last-'0'+1)%10))
+}
+
+func TestRegister(t *testing.T) {
+	login := uniqueLogin(t)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "ok", body: `{"login":"` + login + `","password":"secret"}`, want: http.StatusOK},
+		{name: "login taken", body: `{"login":"` + login + `","password":"other-secret"}`, want: http.StatusConflict},
+		{name: "malformed json", body: `{"login":`, want: http.StatusBadRequest},
+		{name: "empty password", body: `{"login":"` + login + `-2","password":""}`, want: http.StatusBadRequest},
+		{name: "short password", body: `{"login":"` + login + `-3","password":"abc"}`, want: http.StatusBadRequest},
+		{name: "breached password", body: `{"login":"` + login + `-4","password":"qwerty123"}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newClient(t).do(http.MethodPost, "/api/user/register", "application/json", []byte(tt.body), nil)
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	login := uniqueLogin(t)
+	newClient(t).register(login, "secret")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "ok", body: `{"login":"` + login + `","password":"secret"}`, want: http.StatusOK},
+		{name: "wrong password", body: `{"login":"` + login + `","password":"wrong"}`, want: http.StatusUnauthorized},
+		{name: "unknown login", body: `{"login":"` + login + `-unknown","password":"secret"}`, want: http.StatusUnauthorized},
+		{name: "malformed json", body: `not json`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newClient(t).do(http.MethodPost, "/api/user/login", "application/json", []byte(tt.body), nil)
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	c := newClient(t)
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodPost, path: "/api/user/orders"},
+		{method: http.MethodGet, path: "/api/user/orders"},
+		{method: http.MethodGet, path: "/api/user/balance"},
+		{method: http.MethodPost, path: "/api/user/balance/withdraw"},
+		{method: http.MethodGet, path: "/api/user/withdrawals"},
+		{method: http.MethodPost, path: "/api/user/password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			resp := c.do(tt.method, tt.path, "text/plain", nil, nil)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			assert.Equal(t, `Bearer realm="gophermart"`, resp.Header.Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestLoyaltyFlow(t *testing.T) {
+	var (
+		processedOrder = newOrderNumber()
+		invalidOrder   = newOrderNumber()
+		withdrawOrder  = newOrderNumber()
+		badLuhnOrder   = withBadChecksum(newOrderNumber())
+		accrualSum     = 729.98
+	)
+	accrual.SetOrder(processedOrder, accrualstub.OrderScript{Steps: []accrualstub.Step{
+		{Status: model.AccrualStatusRegistered},
+		{After: 500 * time.Millisecond, Status: model.AccrualStatusProcessed, Accrual: &accrualSum},
+	}})
+	accrual.SetOrder(invalidOrder, accrualstub.OrderScript{Steps: []accrualstub.Step{
+		{Status: model.AccrualStatusInvalid},
+	}})
+
+	login := uniqueLogin(t)
+	newClient(t).register(login, "secret")
+
+	c := newClient(t)
+	resp := c.postJSON("/api/user/login", model.AuthRequest{Login: login, Password: "secret"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.Equal(t, http.StatusNoContent, c.get("/api/user/orders").StatusCode)
+	assert.Equal(t, http.StatusNoContent, c.get("/api/user/withdrawals").StatusCode)
+
+	assert.Equal(t, http.StatusAccepted, c.postText("/api/user/orders", processedOrder).StatusCode)
+	assert.Equal(t, http.StatusOK, c.postText("/api/user/orders", processedOrder).StatusCode)
+	assert.Equal(t, http.StatusAccepted, c.postText("/api/user/orders", invalidOrder).StatusCode)
+	assert.Equal(t, http.StatusUnprocessableEntity, c.postText("/api/user/orders", badLuhnOrder).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, c.postText("/api/user/orders", "").StatusCode)
+	assert.Equal(t, http.StatusBadRequest,
+		c.do(http.MethodPost, "/api/user/orders", "application/json", []byte(processedOrder), nil).StatusCode)
+
+	other := newClient(t)
+	other.register(uniqueLogin(t)+"-other", "secret")
+	assert.Equal(t, http.StatusConflict, other.postText("/api/user/orders", processedOrder).StatusCode)
+
+	require.Eventually(t, func() bool {
+		resp := c.get("/api/user/orders")
+		if resp.StatusCode != http.StatusOK {
+			return false
+		}
+
+		var orders []model.Order
+		if err := json.NewDecoder(resp.Body).Decode(&orders); err != nil {
+			return false
+		}
+		statuses := make(map[string]model.OrderStatus)
+		for _, order := range orders {
+			statuses[order.Number] = order.Status
+		}
+		return statuses[processedOrder] == model.OrderStatusProcessed &&
+			statuses[invalidOrder] == model.OrderStatusInvalid
+	}, 10*time.Second, 200*time.Millisecond)
+
+	resp = c.get("/api/user/orders")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var orders []struct {
+		Number     string   `json:"number"`
+		Status     string   `json:"status"`
+		Accrual    *float64 `json:"accrual"`
+		UploadedAt string   `json:"uploaded_at"`
+	}
+	decode(t, resp, &orders)
+	require.Len(t, orders, 2)
+	assert.Equal(t, invalidOrder, orders[0].Number, "newest order first")
+	assert.Nil(t, orders[0].Accrual)
+	require.NotNil(t, orders[1].Accrual)
+	assert.InDelta(t, 729.98, *orders[1].Accrual, 0.001)
+	_, err := time.Parse(time.RFC3339, orders[1].UploadedAt)
+	assert.NoError(t, err)
+
+	var balance model.Balance
+	resp = c.get("/api/user/balance")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	decode(t, resp, &balance)
+	assert.InDelta(t, 729.98, balance.Current, 0.001)
+	assert.InDelta(t, 0, balance.Withdrawn, 0.001)
+
+	withdraw := func(order string, sum float64) int {
+		return c.postJSON("/api/user/balance/withdraw", model.WithdrawRequest{Order: order, Sum: sum}).StatusCode
+	}
+	assert.Equal(t, http.StatusPaymentRequired, withdraw(withdrawOrder, 1000))
+	assert.Equal(t, http.StatusUnprocessableEntity, withdraw(badLuhnOrder, 10))
+	assert.Equal(t, http.StatusUnprocessableEntity, withdraw(withdrawOrder, 0.001), "sum rounds to zero")
+	assert.Equal(t, http.StatusUnprocessableEntity, withdraw(withdrawOrder, 10.005))
+	assert.Equal(t, http.StatusOK, withdraw(withdrawOrder, 700))
+
+	resp = c.get("/api/user/balance")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	decode(t, resp, &balance)
+	assert.InDelta(t, 29.98, balance.Current, 0.001)
+	assert.InDelta(t, 700, balance.Withdrawn, 0.001)
+
+	resp = c.get("/api/user/withdrawals")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var withdrawals []struct {
+		Order       string  `json:"order"`
+		Sum         float64 `json:"sum"`
+		ProcessedAt string  `json:"processed_at"`
+	}
+	decode(t, resp, &withdrawals)
+	require.Len(t, withdrawals, 1)
+	assert.Equal(t, withdrawOrder, withdrawals[0].Order)
+	assert.InDelta(t, 700, withdrawals[0].Sum, 0.001)
+	_, err = time.Parse(time.RFC3339, withdrawals[0].ProcessedAt)
+	assert.NoError(t, err)
+}
+
+func TestGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(`{"login":"` + uniqueLogin(t) + `","password":"secret"}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	c := newClient(t)
+	resp := c.do(http.MethodPost, "/api/user/register", "application/json", buf.Bytes(),
+		map[string]string{"Content-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = c.do(http.MethodGet, "/api/user/balance", "", nil, map[string]string{"Accept-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	zr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	var balance model.Balance
+	require.NoError(t, json.Unmarshal(body, &balance))
+	assert.Zero(t, balance.Current)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	cfg := util.GetConfig().Auth
+	refreshCookie := func(resp *http.Response) string {
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == cfg.RefreshCookieName {
+				return cookie.Value
+			}
+		}
+		return ""
+	}
+
+	c := newClient(t)
+	resp := c.postJSON("/api/user/register", model.AuthRequest{Login: uniqueLogin(t), Password: "secret"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	stolen := refreshCookie(resp)
+	require.NotEmpty(t, stolen)
+
+	resp = c.do(http.MethodPost, "/api/user/token/refresh", "", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	rotated := refreshCookie(resp)
+	require.NotEmpty(t, rotated)
+	assert.NotEqual(t, stolen, rotated)
+	assert.Equal(t, http.StatusOK, c.get("/api/user/balance").StatusCode)
+
+	attacker := newClient(t)
+	resp = attacker.postJSON("/api/user/token/refresh", model.RefreshRequest{RefreshToken: stolen})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "reused token must be rejected")
+
+	assert.Equal(t, http.StatusUnauthorized, c.get("/api/user/balance").StatusCode, "session must be revoked")
+	resp = c.do(http.MethodPost, "/api/user/token/refresh", "", nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "whole family must be revoked")
+
+	assert.Equal(t, http.StatusBadRequest, newClient(t).do(http.MethodPost, "/api/user/token/refresh", "", nil, nil).StatusCode)
+}
+
+func TestBearerAuth(t *testing.T) {
+	login := uniqueLogin(t)
+	newClient(t).register(login, "secret")
+
+	mobile := &client{t: t, http: &http.Client{}}
+	resp := mobile.postJSON("/api/user/login?return_tokens=true", model.AuthRequest{Login: login, Password: "secret"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tokens model.TokenResponse
+	decode(t, resp, &tokens)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	require.NotEmpty(t, tokens.AccessToken)
+	require.NotEmpty(t, tokens.RefreshToken)
+	assert.Equal(t, util.GetConfig().Auth.AccessTokenTTL, tokens.ExpiresIn)
+
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+	resp = mobile.do(http.MethodGet, "/api/user/balance", "", nil, bearer(tokens.AccessToken))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = mobile.do(http.MethodGet, "/api/user/balance", "", nil, bearer("garbage"))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Bearer realm="gophermart", error="invalid_token"`, resp.Header.Get("WWW-Authenticate"))
+
+	data, err := json.Marshal(model.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	require.NoError(t, err)
+	resp = mobile.do(http.MethodPost, "/api/user/token/refresh?return_tokens=true", "application/json", data, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var rotated model.TokenResponse
+	decode(t, resp, &rotated)
+	assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+
+	resp = mobile.do(http.MethodGet, "/api/user/balance", "", nil, bearer(rotated.AccessToken))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = mobile.postJSON("/api/user/login", model.AuthRequest{Login: login, Password: "secret"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, body, "tokens are only returned on request")
+}
+
+func TestLoginLockout(t *testing.T) {
+	login := uniqueLogin(t)
+	newClient(t).register(login, "secret")
+	lockout := util.GetConfig().Auth.Lockout
+
+	c := newClient(t)
+	attempt := func(password string) *http.Response {
+		return c.postJSON("/api/user/login", model.AuthRequest{Login: login, Password: password})
+	}
+	require.Equal(t, http.StatusUnauthorized, attempt("wrong").StatusCode)
+	require.Equal(t, http.StatusOK, attempt("secret").StatusCode)
+	for i := 0; i < lockout.Threshold; i++ {
+		require.Equal(t, http.StatusUnauthorized, attempt("wrong").StatusCode, "counter is reset after success")
+	}
+
+	resp := attempt("secret")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, "locked even with the right password")
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	require.NoError(t, err)
+	assert.Positive(t, retryAfter)
+	assert.LessOrEqual(t, int64(retryAfter), lockout.BaseDelay)
+}
+
+func TestChangePassword(t *testing.T) {
+	login := uniqueLogin(t)
+	c := newClient(t)
+	c.register(login, "secret")
+
+	other := newClient(t)
+	resp := other.postJSON("/api/user/login", model.AuthRequest{Login: login, Password: "secret"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, http.StatusOK, other.get("/api/user/balance").StatusCode)
+
+	change := func(current, next string) int {
+		return c.postJSON("/api/user/password", model.ChangePasswordRequest{CurrentPassword: current, NewPassword: next}).StatusCode
+	}
+	assert.Equal(t, http.StatusForbidden, change("wrong", "new-secret"))
+	assert.Equal(t, http.StatusBadRequest, change("secret", "abc"))
+	assert.Equal(t, http.StatusBadRequest, change("secret", "password"))
+	assert.Equal(t, http.StatusBadRequest, change("secret", ""))
+	require.Equal(t, http.StatusOK, change("secret", "new-secret"))
+
+	assert.Equal(t, http.StatusOK, c.get("/api/user/balance").StatusCode, "caller gets a new session")
+	assert.Equal(t, http.StatusUnauthorized, other.get("/api/user/balance").StatusCode, "other sessions are invalidated")
+	resp = other.do(http.MethodPost, "/api/user/token/refresh", "", nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = newClient(t).postJSON("/api/user/login", model.AuthRequest{Login: login, Password: "secret"})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = newClient(t).postJSON("/api/user/login", model.AuthRequest{Login: login, Password: "new-secret"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAPIKeys(t *testing.T) {
+	customerLogin := uniqueLogin(t)
+	customer := newClient(t)
+	customer.register(customerLogin, "secret")
+	otherLogin := uniqueLogin(t) + "-other"
+	newClient(t).register(otherLogin, "secret")
+
+	merchantLogin := uniqueLogin(t) + "-merchant"
+	merchant := newClient(t)
+	merchant.register(merchantLogin, "secret")
+	assert.Equal(t, http.StatusNoContent, merchant.get("/api/user/api-keys").StatusCode)
+
+	storefrontKey := model.APIKeyRequest{
+		Name:   "storefront",
+		Scopes: []string{model.ScopeOrdersWrite, model.ScopeOrdersRead},
+	}
+	resp := merchant.postJSON("/api/user/api-keys", storefrontKey)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "only merchants create keys")
+
+	require.Equal(t, http.StatusNoContent, newAdminClient(t).setRole(merchantLogin, model.RoleMerchant))
+	require.Equal(t, http.StatusOK, merchant.do(http.MethodPost, "/api/user/token/refresh", "", nil, nil).StatusCode)
+
+	resp = merchant.postJSON("/api/user/api-keys", model.APIKeyRequest{Name: "storefront", Scopes: []string{"unknown"}})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = merchant.postJSON("/api/user/api-keys", storefrontKey)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var issued model.IssuedAPIKey
+	decode(t, resp, &issued)
+	require.NotEmpty(t, issued.Key)
+
+	storefront := &client{t: t, http: &http.Client{}}
+	callFor := func(login, method, path, key, body string) int {
+		headers := map[string]string{"X-API-Key": key, "X-Customer-Login": login}
+		return storefront.do(method, path, "text/plain", []byte(body), headers).StatusCode
+	}
+	call := func(method, path, key, body string) int {
+		return callFor(customerLogin, method, path, key, body)
+	}
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/api/user/orders", issued.Key, ""), "customer hasn't linked the merchant")
+
+	link := func(login string) int {
+		return customer.postJSON("/api/user/merchants", model.MerchantRequest{Merchant: login}).StatusCode
+	}
+	assert.Equal(t, http.StatusNotFound, link(merchantLogin+"-unknown"))
+	assert.Equal(t, http.StatusUnprocessableEntity, link(otherLogin), "only merchants can be linked")
+	require.Equal(t, http.StatusNoContent, link(merchantLogin))
+
+	order := newOrderNumber()
+	assert.Equal(t, http.StatusAccepted, call(http.MethodPost, "/api/user/orders", issued.Key, order))
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "/api/user/orders", issued.Key, ""))
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/api/user/balance", issued.Key, ""), "scope not granted")
+	assert.Equal(t, http.StatusForbidden, call(http.MethodPost, "/api/user/balance/withdraw", issued.Key, ""), "session only")
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/api/user/api-keys", issued.Key, ""), "session only")
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/user/orders", issued.Key+"x", ""))
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/user/orders", "garbage", ""))
+
+	assert.Equal(t, http.StatusForbidden, callFor(otherLogin, http.MethodGet, "/api/user/orders", issued.Key, ""),
+		"unlinked customer is rejected")
+	assert.Equal(t, http.StatusForbidden, callFor(otherLogin, http.MethodPost, "/api/user/orders", issued.Key, newOrderNumber()))
+	assert.Equal(t, http.StatusForbidden, callFor(customerLogin+"-unknown", http.MethodGet, "/api/user/orders", issued.Key, ""),
+		"unknown customer looks like an unlinked one")
+
+	resp = customer.get("/api/user/orders")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var orders []model.Order
+	decode(t, resp, &orders)
+	require.Len(t, orders, 1)
+	assert.Equal(t, order, orders[0].Number, "order belongs to the customer")
+
+	resp = customer.get("/api/user/merchants")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var merchants []model.LinkedMerchant
+	decode(t, resp, &merchants)
+	require.Len(t, merchants, 1)
+	assert.Equal(t, merchantLogin, merchants[0].Merchant)
+
+	resp = merchant.get("/api/user/api-keys")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var keys []map[string]interface{}
+	decode(t, resp, &keys)
+	require.Len(t, keys, 1)
+	assert.NotContains(t, keys[0], "key", "secret is shown only once")
+	assert.NotNil(t, keys[0]["last_used_at"])
+
+	require.Equal(t, http.StatusNoContent,
+		customer.do(http.MethodDelete, "/api/user/merchants/"+merchantLogin, "", nil, nil).StatusCode)
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/api/user/orders", issued.Key, ""), "consent withdrawn")
+	assert.Equal(t, http.StatusNoContent, customer.get("/api/user/merchants").StatusCode)
+
+	assert.Equal(t, http.StatusNotFound,
+		customer.do(http.MethodDelete, "/api/user/api-keys/"+issued.ID.String(), "", nil, nil).StatusCode,
+		"keys of other merchants are not visible")
+	assert.Equal(t, http.StatusNoContent,
+		merchant.do(http.MethodDelete, "/api/user/api-keys/"+issued.ID.String(), "", nil, nil).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, "/api/user/orders", issued.Key, ""), "revoked key")
+}
+
+func TestAdminAccess(t *testing.T) {
+	customerLogin := uniqueLogin(t)
+	customer := newClient(t)
+	customer.register(customerLogin, "secret")
+	order := newOrderNumber()
+	require.Equal(t, http.StatusAccepted, customer.postText("/api/user/orders", order).StatusCode)
+
+	admin := newAdminClient(t)
+	assert.Equal(t, http.StatusForbidden, customer.get("/api/admin/users/"+customerLogin+"/orders").StatusCode)
+
+	late := newClient(t)
+	resp := late.postJSON("/api/user/register", model.AuthRequest{Login: lateAdminLogin, Password: "secret"})
+	if resp.StatusCode == http.StatusConflict {
+		resp = late.postJSON("/api/user/login", model.AuthRequest{Login: lateAdminLogin, Password: "secret"})
+	}
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.StatusForbidden, late.get("/api/admin/users/"+customerLogin).StatusCode,
+		"registering an admin login doesn't grant the role")
+
+	resp = admin.get("/api/admin/users/" + customerLogin + "/orders")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var orders []model.Order
+	decode(t, resp, &orders)
+	require.Len(t, orders, 1)
+	assert.Equal(t, order, orders[0].Number)
+	assert.Equal(t, http.StatusOK, admin.get("/api/admin/users/"+customerLogin+"/balance").StatusCode)
+	assert.Equal(t, http.StatusNotFound, admin.get("/api/admin/users/"+customerLogin+"-unknown/balance").StatusCode)
+	assert.Equal(t, http.StatusNoContent, admin.get("/api/admin/orders/review").StatusCode, "no order is stuck")
+	assert.Equal(t, http.StatusForbidden, customer.get("/api/admin/orders/review").StatusCode)
+
+	supportLogin := uniqueLogin(t) + "-support"
+	support := newClient(t)
+	support.register(supportLogin, "secret")
+
+	assert.Equal(t, http.StatusBadRequest, admin.setRole(supportLogin, "root"))
+	require.Equal(t, http.StatusNoContent, admin.setRole(supportLogin, model.RoleSupport))
+
+	assert.Equal(t, http.StatusUnauthorized, support.get("/api/admin/users/"+customerLogin).StatusCode,
+		"tokens with the old role are rejected")
+	require.Equal(t, http.StatusOK, support.do(http.MethodPost, "/api/user/token/refresh", "", nil, nil).StatusCode)
+
+	resp = support.get("/api/admin/users/" + customerLogin)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var user model.User
+	decode(t, resp, &user)
+	assert.Equal(t, customerLogin, user.Login)
+	assert.Equal(t, model.RoleUser, user.Role)
+	assert.Equal(t, http.StatusForbidden, support.setRole(customerLogin, model.RoleAdmin), "only admins change roles")
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	login := uniqueLogin(t)
+	c := newClient(t)
+	c.register(login, "secret")
+
+	resp := c.do(http.MethodPost, "/api/user/2fa/enroll", "", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var enrollment model.TOTPEnrollment
+	decode(t, resp, &enrollment)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/")
+
+	code := func(offset int64) string {
+		code, err := totp.Code(enrollment.Secret, totp.Step(time.Now())+offset)
+		require.NoError(t, err)
+		return code
+	}
+	resp = c.postJSON("/api/user/2fa/confirm", model.TOTPRequest{Code: "000000"})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	resp = c.postJSON("/api/user/2fa/confirm", model.TOTPRequest{Code: code(0)})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var recovery model.RecoveryCodes
+	decode(t, resp, &recovery)
+	require.Len(t, recovery.Codes, 10)
+	assert.Equal(t, http.StatusConflict, c.do(http.MethodPost, "/api/user/2fa/enroll", "", nil, nil).StatusCode)
+
+	browser := newClient(t)
+	resp = browser.postJSON("/api/user/login", model.AuthRequest{Login: login, Password: "secret"})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var preAuth model.PreAuthResponse
+	decode(t, resp, &preAuth)
+	require.NotEmpty(t, preAuth.PreAuthToken)
+
+	assert.Equal(t, http.StatusUnauthorized, browser.get("/api/user/balance").StatusCode)
+	resp = browser.do(http.MethodGet, "/api/user/balance", "", nil,
+		map[string]string{"Authorization": "Bearer " + preAuth.PreAuthToken})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "pre-auth token is not an access token")
+
+	second := func(c *client, req model.TwoFactorLoginRequest) int {
+		return c.postJSON("/api/user/login/2fa", req).StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, second(browser, model.TwoFactorLoginRequest{Code: "000000"}))
+	assert.Equal(t, http.StatusUnauthorized, second(browser, model.TwoFactorLoginRequest{Code: code(0)}),
+		"code used for confirmation can't be replayed")
+	require.Equal(t, http.StatusOK, second(browser, model.TwoFactorLoginRequest{Code: code(1)}))
+	assert.Equal(t, http.StatusOK, browser.get("/api/user/balance").StatusCode)
+
+	mobile := &client{t: t, http: &http.Client{}}
+	resp = mobile.postJSON("/api/user/login", model.AuthRequest{Login: login, Password: "secret"})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	decode(t, resp, &preAuth)
+	req := model.TwoFactorLoginRequest{PreAuthToken: preAuth.PreAuthToken, RecoveryCode: strings.ToUpper(recovery.Codes[0])}
+	assert.Equal(t, http.StatusOK, second(mobile, req))
+	assert.Equal(t, http.StatusUnauthorized, second(mobile, req), "recovery code is single use")
+	assert.Equal(t, http.StatusUnauthorized, second(newClient(t), model.TwoFactorLoginRequest{Code: code(0)}),
+		"pre-auth token is required")
+}
+
+func TestSessions(t *testing.T) {
+	login := uniqueLogin(t)
+	laptop := newClient(t)
+	laptop.register(login, "secret")
+
+	data, err := json.Marshal(model.AuthRequest{Login: login, Password: "secret"})
+	require.NoError(t, err)
+	phone := newClient(t)
+	resp := phone.do(http.MethodPost, "/api/user/login", "application/json", data, map[string]string{
+		"X-Device-Name":   "phone",
+		"X-Forwarded-For": "203.0.113.7",
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = laptop.get("/api/user/sessions")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var sessions []model.Session
+	decode(t, resp, &sessions)
+	require.Len(t, sessions, 2)
+	var current, other model.Session
+	for _, s := range sessions {
+		if s.Current {
+			current = s
+		} else {
+			other = s
+		}
+	}
+	require.True(t, current.Current, "current session is marked")
+	assert.Equal(t, "phone", other.Device)
+	assert.NotEmpty(t, other.UserAgent)
+	assert.Equal(t, "127.0.0.1", other.IP, "forwarded headers of untrusted peers are ignored")
+
+	stranger := newClient(t)
+	stranger.register(uniqueLogin(t)+"-stranger", "secret")
+	assert.Equal(t, http.StatusNotFound,
+		stranger.do(http.MethodDelete, "/api/user/sessions/"+other.ID.String(), "", nil, nil).StatusCode,
+		"sessions of other users are not visible")
+
+	assert.Equal(t, http.StatusNoContent,
+		laptop.do(http.MethodDelete, "/api/user/sessions/"+other.ID.String(), "", nil, nil).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, phone.get("/api/user/balance").StatusCode, "revoked session")
+	assert.Equal(t, http.StatusUnauthorized,
+		phone.do(http.MethodPost, "/api/user/token/refresh", "", nil, nil).StatusCode)
+	assert.Equal(t, http.StatusOK, laptop.get("/api/user/balance").StatusCode)
+
+	resp = laptop.do(http.MethodPost, "/api/user/logout", "", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	for _, cookie := range resp.Cookies() {
+		assert.Empty(t, cookie.Value, "cookie %s must be cleared", cookie.Name)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	}
+	assert.Equal(t, http.StatusUnauthorized, laptop.get("/api/user/balance").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, laptop.do(http.MethodPost, "/api/user/logout", "", nil, nil).StatusCode)
+}
+
+func TestCSRF(t *testing.T) {
+	login := uniqueLogin(t)
+	browser := newClient(t)
+	browser.register(login, "secret")
+
+	withToken := func(token string) map[string]string {
+		return map[string]string{middleware.CSRFHeader: token}
+	}
+	upload := func(headers map[string]string) int {
+		return browser.do(http.MethodPost, "/api/user/orders", "text/plain", []byte(newOrderNumber()), headers).StatusCode
+	}
+	assert.Equal(t, http.StatusForbidden, upload(withToken("")), "missing token")
+	assert.Equal(t, http.StatusForbidden, upload(withToken("forged")), "wrong token")
+	assert.Equal(t, http.StatusAccepted, upload(nil))
+	assert.Equal(t, http.StatusOK, browser.get("/api/user/orders").StatusCode, "safe methods are not checked")
+
+	resp := browser.get("/api/user/csrf")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var csrf model.CSRFTokenResponse
+	decode(t, resp, &csrf)
+	assert.Equal(t, http.StatusAccepted, upload(withToken(csrf.CSRFToken)), "endpoint returns the cookie token")
+
+	mobile := &client{t: t, http: &http.Client{}}
+	resp = mobile.postJSON("/api/user/login?return_tokens=true", model.AuthRequest{Login: login, Password: "secret"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tokens model.TokenResponse
+	decode(t, resp, &tokens)
+	headers := map[string]string{"Authorization": "Bearer " + tokens.AccessToken}
+	resp = mobile.do(http.MethodPost, "/api/user/orders", "text/plain", []byte(newOrderNumber()), headers)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode, "bearer requests are exempt")
+}
+
+func TestAccrualCallback(t *testing.T) {
+	customer := newClient(t)
+	customer.register(uniqueLogin(t), "secret")
+	order := newOrderNumber()
+	require.Equal(t, http.StatusAccepted, customer.postText("/api/user/orders", order).StatusCode)
+
+	accrualSystem := &client{t: t, http: &http.Client{}}
+	callback := func(update model.AccrualOrder, secret string) int {
+		data, err := json.Marshal(update)
+		require.NoError(t, err)
+		headers := map[string]string{middleware.AccrualSignatureHeader: middleware.AccrualSignature(secret, data)}
+		return accrualSystem.do(http.MethodPost, "/internal/accrual/callback", "application/json", data, headers).StatusCode
+	}
+
+	sum := 150.5
+	processed := model.AccrualOrder{Order: order, Status: model.AccrualStatusProcessed, Accrual: &sum}
+	assert.Equal(t, http.StatusUnauthorized, callback(processed, "wrong-secret"))
+	assert.Equal(t, http.StatusBadRequest, callback(model.AccrualOrder{Order: order, Status: "DONE"}, callbackSecret))
+	assert.Equal(t, http.StatusNotFound, callback(model.AccrualOrder{Order: newOrderNumber(), Status: model.AccrualStatusProcessing}, callbackSecret))
+
+	assert.Equal(t, http.StatusOK, callback(model.AccrualOrder{Order: order, Status: model.AccrualStatusProcessing}, callbackSecret))
+	assert.Equal(t, http.StatusOK, callback(processed, callbackSecret))
+	assert.Equal(t, http.StatusOK, callback(processed, callbackSecret), "repeated delivery")
+
+	resp := customer.get("/api/user/balance")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var balance model.Balance
+	decode(t, resp, &balance)
+	assert.InDelta(t, sum, balance.Current, 0.001, "accrual is credited once")
+
+	resp = customer.get("/api/user/orders")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var orders []model.Order
+	decode(t, resp, &orders)
+	require.Len(t, orders, 1)
+	assert.Equal(t, model.OrderStatusProcessed, orders[0].Status)
+}
+
+func TestAccrualCallbackClearsReview(t *testing.T) {
+	customer := newClient(t)
+	customer.register(uniqueLogin(t), "secret")
+	order := newOrderNumber()
+	require.Equal(t, http.StatusAccepted, customer.postText("/api/user/orders", order).StatusCode)
+
+	// Flag the order as if it had been pending for too long. The job is
+	// leased under another owner once the worker pool has released it.
+	ctx := context.Background()
+	flagged := false
+	for deadline := time.Now().Add(5 * time.Second); !flagged && time.Now().Before(deadline); {
+		jobs, err := store.LeaseAccrualJobs(ctx, t.Name(), 1000, time.Minute)
+		require.NoError(t, err)
+		for _, job := range jobs {
+			if job.OrderNumber == order {
+				require.NoError(t, store.FlagAccrualJob(ctx, &job))
+				flagged = true
+				continue
+			}
+			require.NoError(t, store.RetryAccrualJob(ctx, &job))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.True(t, flagged)
+
+	admin := newAdminClient(t)
+	reviewed := func() bool {
+		resp := admin.get("/api/admin/orders/review")
+		if resp.StatusCode == http.StatusNoContent {
+			return false
+		}
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var jobs []model.AccrualJob
+		decode(t, resp, &jobs)
+		for _, job := range jobs {
+			if job.OrderNumber == order {
+				return true
+			}
+		}
+		return false
+	}
+	require.True(t, reviewed())
+
+	sum := 42.0
+	data, err := json.Marshal(model.AccrualOrder{Order: order, Status: model.AccrualStatusProcessed, Accrual: &sum})
+	require.NoError(t, err)
+	headers := map[string]string{middleware.AccrualSignatureHeader: middleware.AccrualSignature(callbackSecret, data)}
+	resp := (&client{t: t, http: &http.Client{}}).do(http.MethodPost, "/internal/accrual/callback", "application/json", data, headers)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.False(t, reviewed(), "finished order leaves the review list")
+}
@@ -2,8 +2,9 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/ypxd99/yandex-diplom-56/internal/service"
 	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/internal/service"
 	"github.com/ypxd99/yandex-diplom-56/util"
 )
 
@@ -16,24 +17,70 @@ func InitHandler(service service.GophermartService) *Handler {
 }
 
 func (h *Handler) InitRoutes(r *gin.Engine) {
+	// The client IP feeds the login lockout, auth events and sessions, so
+	// forwarded headers are only honoured from the configured proxies.
+	if err := r.SetTrustedProxies(util.GetConfig().Server.TrustedProxies); err != nil {
+		util.GetLogger().Errorf("failed to set trusted proxies: %v", err)
+	}
+
 	util.GetMetricsRoute(r)
 	util.GetHealthcheckRoute(r)
 	util.GetRouteList(r)
+	util.GetJWKSRoute(r, middleware.PublicJWKS)
+
+	// Service-to-service routes are registered before the user middleware,
+	// so cookies, tokens and CSRF checks don't apply to them.
+	if secret := util.GetConfig().Accrual.CallbackSecret; secret != "" {
+		internalAPI := r.Group("/internal", middleware.LoggingMiddleware())
+		internalAPI.POST("/accrual/callback", middleware.AccrualSignatureMiddleware(secret), h.accrualCallback)
+	}
 
 	r.Use(middleware.LoggingMiddleware())
 	r.Use(middleware.GzipMiddleware())
-	r.Use(middleware.AuthMiddleware())
+	r.Use(middleware.AuthMiddleware(h.service))
+	r.Use(middleware.APIKeyMiddleware(h.service))
+
+	rAPI := r.Group("/api")
+	rAPI.Use(middleware.CSRFMiddleware())
+
+	userAPI := rAPI.Group("/user")
+	userAPI.GET("/csrf", h.getCSRFToken)
+	userAPI.POST("/register", h.register)
+	userAPI.POST("/login", h.login)
+	userAPI.POST("/login/2fa", h.loginSecondFactor)
+	userAPI.POST("/token/refresh", h.refreshToken)
 
-	// r.POST("/", h.shorterLink)
-	// r.GET("/:id", h.getLinkByID)
-	// r.GET("/ping", h.getStorageStatus)
+	authAPI := userAPI.Group("")
+	authAPI.Use(middleware.RequireAuth())
+	authAPI.POST("/orders", middleware.RequireScope(model.ScopeOrdersWrite), h.uploadOrder)
+	authAPI.GET("/orders", middleware.RequireScope(model.ScopeOrdersRead), h.getUserOrders)
+	authAPI.GET("/balance", middleware.RequireScope(model.ScopeBalanceRead), h.getBalance)
+	authAPI.GET("/withdrawals", middleware.RequireScope(model.ScopeWithdrawalsRead), h.getUserWithdrawals)
 
-	// rAPI := r.Group("/api")
-	// rAPI.POST("/shorten", h.shorten)
-	// rAPI.POST("/shorten/batch", h.batchShorten)
+	sessionAPI := authAPI.Group("")
+	sessionAPI.Use(middleware.RequireSession())
+	sessionAPI.POST("/logout", h.logout)
+	sessionAPI.GET("/sessions", h.getSessions)
+	sessionAPI.DELETE("/sessions/:id", h.revokeSession)
+	sessionAPI.POST("/password", h.changePassword)
+	sessionAPI.POST("/2fa/enroll", h.enrollTOTP)
+	sessionAPI.POST("/2fa/confirm", h.confirmTOTP)
+	sessionAPI.POST("/balance/withdraw", h.withdraw)
+	sessionAPI.POST("/api-keys", h.createAPIKey)
+	sessionAPI.GET("/api-keys", h.getAPIKeys)
+	sessionAPI.DELETE("/api-keys/:id", h.revokeAPIKey)
+	sessionAPI.POST("/merchants", h.linkMerchant)
+	sessionAPI.GET("/merchants", h.getLinkedMerchants)
+	sessionAPI.DELETE("/merchants/:login", h.unlinkMerchant)
 
-	// userAPI := rAPI.Group("/user")
-	// userAPI.Use(middleware.RequireAuth())
-	// userAPI.GET("/urls", h.getUserURLs)
-	// userAPI.DELETE("/urls", h.deleteURLs)
+	adminAPI := rAPI.Group("/admin")
+	adminAPI.Use(middleware.RequireAuth(), middleware.RequireSession())
+	adminAPI.Use(middleware.RequireRole(model.RoleSupport, model.RoleAdmin))
+	adminAPI.GET("/users/:login", h.adminGetUser)
+	adminAPI.GET("/users/:login/orders", h.adminGetUserOrders)
+	adminAPI.GET("/users/:login/balance", h.adminGetUserBalance)
+	adminAPI.GET("/users/:login/withdrawals", h.adminGetUserWithdrawals)
+	adminAPI.GET("/users/:login/auth-events", h.adminGetAuthEvents)
+	adminAPI.GET("/orders/review", h.adminGetOrdersForReview)
+	adminAPI.PUT("/users/:login/role", middleware.RequireRole(model.RoleAdmin), h.adminSetUserRole)
 }
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (h *Handler) uploadOrder(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	if !strings.HasPrefix(c.ContentType(), "text/plain") {
+		responseTextPlain(c, http.StatusBadRequest, errors.New("content type must be text/plain"), nil)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		responseTextPlain(c, http.StatusBadRequest, err, nil)
+		return
+	}
+	number := strings.TrimSpace(string(body))
+	if number == "" {
+		responseTextPlain(c, http.StatusBadRequest, errors.New("empty order number"), nil)
+		return
+	}
+
+	err = h.service.UploadOrder(c.Request.Context(), userID, number)
+	switch {
+	case err == nil:
+		c.Status(http.StatusAccepted)
+	case errors.Is(err, model.ErrOrderAlreadyUploaded):
+		c.Status(http.StatusOK)
+	case errors.Is(err, model.ErrOrderUploadedByAnother):
+		responseTextPlain(c, http.StatusConflict, err, nil)
+	case errors.Is(err, model.ErrInvalidOrderNumber):
+		responseTextPlain(c, http.StatusUnprocessableEntity, err, nil)
+	default:
+		responseTextPlain(c, http.StatusInternalServerError, err, nil)
+	}
+}
+
+func (h *Handler) getUserOrders(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	orders, err := h.service.GetUserOrders(c.Request.Context(), userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(orders) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, orders)
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+// deviceHeader lets clients name the device a session is opened from, e.g.
+// "Alice's iPhone". It is shown in the session list.
+const deviceHeader = "X-Device-Name"
+
+// logout revokes the current session and removes the auth cookies.
+func (h *Handler) logout(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+	sessionID, err := middleware.GetSessionID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	err = h.service.RevokeSession(c.Request.Context(), userID, sessionID)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	middleware.ClearAuthCookies(c)
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) getSessions(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+	sessionID, err := middleware.GetSessionID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	sessions, err := h.service.GetSessions(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(sessions) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	response(c, http.StatusOK, nil, sessions)
+}
+
+// revokeSession signs the user out of one of their sessions. Revoking the
+// current session also removes the auth cookies.
+func (h *Handler) revokeSession(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+	sessionID, err := middleware.GetSessionID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	err = h.service.RevokeSession(c.Request.Context(), userID, id)
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		response(c, http.StatusNotFound, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	if id == sessionID {
+		middleware.ClearAuthCookies(c)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// getCSRFToken hands out the CSRF token for clients that can't read the
+// cookie, issuing the cookie if it is missing.
+func (h *Handler) getCSRFToken(c *gin.Context) {
+	token, err := middleware.CSRFToken(c)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, model.CSRFTokenResponse{CSRFToken: token})
+}
+
+func sessionMeta(c *gin.Context) model.SessionMeta {
+	return model.SessionMeta{
+		Device:    c.GetHeader(deviceHeader),
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (h *Handler) enrollTOTP(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	enrollment, err := h.service.EnrollTOTP(c.Request.Context(), userID)
+	switch {
+	case errors.Is(err, model.ErrTOTPAlreadyEnabled):
+		response(c, http.StatusConflict, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, enrollment)
+}
+
+func (h *Handler) confirmTOTP(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	var req model.TOTPRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTP(c.Request.Context(), userID, req.Code)
+	switch {
+	case errors.Is(err, model.ErrInvalidTOTPCode):
+		response(c, http.StatusUnprocessableEntity, err, nil)
+		return
+	case errors.Is(err, model.ErrTOTPAlreadyEnabled):
+		response(c, http.StatusConflict, err, nil)
+		return
+	case errors.Is(err, model.ErrTOTPNotEnrolled):
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, codes)
+}
+
+// loginSecondFactor completes a two-step login started by login.
+func (h *Handler) loginSecondFactor(c *gin.Context) {
+	var req model.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		response(c, http.StatusBadRequest, errors.New("code or recovery code is required"), nil)
+		return
+	}
+
+	userID, err := middleware.GetPreAuthUserID(c, req.PreAuthToken)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	err = h.service.VerifySecondFactor(c.Request.Context(), userID, req, c.ClientIP())
+	var locked *model.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		responseLocked(c, locked)
+		return
+	case errors.Is(err, model.ErrInvalidTOTPCode):
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	middleware.ClearPreAuthToken(c)
+	h.startSession(c, userID)
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/middleware"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+)
+
+func (h *Handler) register(c *gin.Context) {
+	var req model.AuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	userID, err := h.service.Register(c.Request.Context(), req.Login, req.Password)
+	switch {
+	case errors.Is(err, model.ErrInvalidAuthRequest), isPasswordPolicyError(err):
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	case errors.Is(err, model.ErrLoginTaken):
+		response(c, http.StatusConflict, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	h.startSession(c, userID)
+}
+
+func (h *Handler) login(c *gin.Context) {
+	var req model.AuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	userID, err := h.service.Login(c.Request.Context(), req.Login, req.Password, c.ClientIP())
+	var locked *model.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		responseLocked(c, locked)
+		return
+	case errors.Is(err, model.ErrTwoFactorRequired):
+		preAuth, err := middleware.IssuePreAuthToken(c, userID)
+		if err != nil {
+			response(c, http.StatusInternalServerError, err, nil)
+			return
+		}
+		response(c, http.StatusAccepted, nil, preAuth)
+		return
+	case errors.Is(err, model.ErrInvalidAuthRequest):
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	case errors.Is(err, model.ErrInvalidCredentials):
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	h.startSession(c, userID)
+}
+
+// changePassword revokes every session of the user, including the current
+// one, and starts a new session for the caller.
+func (h *Handler) changePassword(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	err = h.service.ChangePassword(c.Request.Context(), userID, req)
+	switch {
+	case errors.Is(err, model.ErrInvalidAuthRequest), isPasswordPolicyError(err):
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	case errors.Is(err, model.ErrWrongPassword):
+		response(c, http.StatusForbidden, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	h.startSession(c, userID)
+}
+
+func (h *Handler) refreshToken(c *gin.Context) {
+	token := middleware.GetRefreshToken(c)
+	if token == "" {
+		var req model.RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			response(c, http.StatusBadRequest, errors.New("refresh token is required"), nil)
+			return
+		}
+		token = req.RefreshToken
+	}
+
+	session, err := h.service.RefreshSession(c.Request.Context(), token, sessionMeta(c))
+	switch {
+	case errors.Is(err, model.ErrInvalidRefreshToken),
+		errors.Is(err, model.ErrRefreshTokenReused):
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	case err != nil:
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	h.issueTokens(c, session)
+}
+
+func (h *Handler) startSession(c *gin.Context, userID uuid.UUID) {
+	session, err := h.service.StartSession(c.Request.Context(), userID, sessionMeta(c))
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	h.issueTokens(c, session)
+}
+
+// issueTokens sets the auth cookies and, when the client asks for it with
+// `?return_tokens=true`, also returns the tokens in the body.
+func (h *Handler) issueTokens(c *gin.Context, session *model.IssuedSession) {
+	tokens, err := middleware.SetAuthCookie(c, session)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	if returnTokens, _ := strconv.ParseBool(c.Query("return_tokens")); returnTokens {
+		response(c, http.StatusOK, nil, tokens)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// responseLocked rejects a login while it is locked out and tells the client
+// when to retry.
+func responseLocked(c *gin.Context, locked *model.LoginLockedError) {
+	retryAfter := int(math.Ceil(time.Until(locked.Until).Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	response(c, http.StatusTooManyRequests, locked, nil)
+}
+
+func isPasswordPolicyError(err error) bool {
+	return errors.Is(err, model.ErrPasswordTooShort) ||
+		errors.Is(err, model.ErrPasswordTooLong) ||
+		errors.Is(err, model.ErrPasswordBreached)
+}
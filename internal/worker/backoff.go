@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff spaces out polls of an order whose status does not change: the
+// delay doubles with every poll up to max, and equal jitter keeps orders
+// uploaded together from being polled in lockstep.
+type backoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+// delay returns the wait before the poll that follows attempts unchanged
+// polls. It lies between half and all of min(base*2^attempts, max).
+func (b backoff) delay(attempts int) time.Duration {
+	d := b.max
+	if attempts < 63 && b.base <= b.max>>attempts {
+		d = b.base << attempts
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := backoff{base: time.Second, max: time.Minute}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Second},
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 5, want: 32 * time.Second},
+		{attempts: 6, want: time.Minute},
+		{attempts: 1000, want: time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			d := b.delay(tt.attempts)
+			assert.GreaterOrEqual(t, d, tt.want/2, "attempts %d", tt.attempts)
+			assert.LessOrEqual(t, d, tt.want, "attempts %d", tt.attempts)
+		}
+	}
+}
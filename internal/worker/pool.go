@@ -0,0 +1,283 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-diplom-56/internal/accrual"
+	"github.com/ypxd99/yandex-diplom-56/internal/model"
+	"github.com/ypxd99/yandex-diplom-56/internal/repository"
+	"github.com/ypxd99/yandex-diplom-56/util"
+)
+
+// Pool polls the accrual system for pending orders with a fixed number of
+// workers. Orders come from the accrual job queue: each batch is leased for
+// the visibility timeout, so several gophermart instances can share the
+// queue without polling the same order at once. The workers share one
+// accrual client, so a 429 or an open circuit breaker holds back the whole
+// pool.
+type Pool struct {
+	repo         repository.GophermartRepo
+	client       *accrual.Client
+	owner        string
+	workers      int
+	batchSize    int
+	pollInterval time.Duration
+	timeout      time.Duration
+	visibility   time.Duration
+	backoff      backoff
+	maxAge       time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type job struct {
+	job   model.AccrualJob
+	batch *sync.WaitGroup
+}
+
+func NewPool(repo repository.GophermartRepo) *Pool {
+	cfg := util.GetConfig().Accrual
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = workers
+	}
+	pollInterval := time.Duration(cfg.PollInterval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	return &Pool{
+		repo:         repo,
+		client:       accrual.NewClient(cfg),
+		owner:        uuid.NewString(),
+		workers:      workers,
+		batchSize:    batchSize,
+		pollInterval: pollInterval,
+		timeout:      time.Duration(cfg.RequestTimeout) * time.Second,
+		visibility:   time.Duration(cfg.VisibilityTimeout) * time.Second,
+		backoff: backoff{
+			base: time.Duration(cfg.Backoff.Base) * time.Second,
+			max:  time.Duration(cfg.Backoff.Max) * time.Second,
+		},
+		maxAge: time.Duration(cfg.Backoff.MaxAge) * time.Second,
+	}
+}
+
+func (p *Pool) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	jobs := make(chan job)
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.work(ctx, jobs)
+		}()
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(jobs)
+		p.dispatch(ctx, jobs)
+	}()
+}
+
+// Stop cancels polling and waits for in-flight requests to finish. Jobs
+// leased but not finished become visible again after the visibility timeout.
+func (p *Pool) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+	util.GetLogger().Info("accrual worker pool stopped")
+}
+
+// dispatch leases due jobs batch by batch and feeds them to the workers. A
+// full batch means more jobs may be due, so the next one is leased right
+// away.
+func (p *Pool) dispatch(ctx context.Context, jobs chan<- job) {
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		var (
+			leased []model.AccrualJob
+			err    error
+		)
+		limit := p.leaseLimit()
+		if limit > 0 {
+			leased, err = p.repo.LeaseAccrualJobs(ctx, p.owner, limit, p.visibility)
+		}
+		if err != nil && ctx.Err() == nil {
+			util.GetLogger().Errorf("failed to lease accrual jobs: %v", err)
+		}
+
+		var batch sync.WaitGroup
+		for _, j := range leased {
+			batch.Add(1)
+			select {
+			case jobs <- job{job: j, batch: &batch}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		batch.Wait()
+
+		if limit > 0 && len(leased) == limit && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// leaseLimit sizes the next batch to the requests the rate limiter lets
+// through before the lease runs out, keeping a request timeout for the last
+// one, so leased jobs don't expire while they wait for the limiter. It is 0
+// while the limiter is paused for longer than that.
+func (p *Pool) leaseLimit() int {
+	limit := p.client.Capacity(p.visibility - p.timeout)
+	if limit > p.batchSize {
+		limit = p.batchSize
+	}
+
+	return limit
+}
+
+func (p *Pool) work(ctx context.Context, jobs <-chan job) {
+	for j := range jobs {
+		if err := p.process(ctx, j.job); err != nil && ctx.Err() == nil {
+			util.GetLogger().Errorf("failed to process order %s: %v", j.job.OrderNumber, err)
+		}
+		j.batch.Done()
+	}
+}
+
+// process polls the accrual system for the order of the job. The job is
+// deleted once the order is final and rescheduled otherwise.
+func (p *Pool) process(ctx context.Context, job model.AccrualJob) error {
+	if job.Order == nil || isFinal(job.Order.Status) {
+		return p.repo.DeleteAccrualJob(ctx, job.OrderNumber, p.owner)
+	}
+
+	// The request must not outlive the lease: once it runs out, another
+	// instance may lease the job and poll the same order.
+	leaseCtx := ctx
+	if job.LeasedUntil != nil {
+		var cancel context.CancelFunc
+		leaseCtx, cancel = context.WithDeadline(ctx, *job.LeasedUntil)
+		defer cancel()
+	}
+
+	res, err := p.client.GetOrder(leaseCtx, job.OrderNumber)
+	var rlErr *accrual.RateLimitError
+	switch {
+	case errors.As(err, &rlErr):
+		return p.reschedule(ctx, job, rlErr.RetryAfter, nil)
+	case errors.Is(err, accrual.ErrCircuitOpen):
+		return p.reschedule(ctx, job, p.pollInterval, nil)
+	case errors.Is(err, accrual.ErrOrderNotRegistered):
+		return p.retry(ctx, job, nil)
+	case err != nil:
+		if ctx.Err() != nil {
+			return err
+		}
+		if leaseCtx.Err() != nil {
+			util.GetLogger().Warnf("lease of order %s expired before the accrual system answered", job.OrderNumber)
+			return p.reschedule(ctx, job, 0, err)
+		}
+		if retryErr := p.retry(ctx, job, err); retryErr != nil {
+			return retryErr
+		}
+		return err
+	}
+
+	status, ok := res.Status.OrderStatus()
+	if !ok {
+		err = errors.Errorf("unknown accrual status %q", res.Status)
+		if retryErr := p.retry(ctx, job, err); retryErr != nil {
+			return retryErr
+		}
+		return err
+	}
+
+	switch {
+	case status == model.OrderStatusProcessed:
+		var amount float64
+		if res.Accrual != nil {
+			amount = *res.Accrual
+		}
+		if err = p.repo.CompleteOrder(ctx, job.OrderNumber, amount); err != nil {
+			return err
+		}
+		return p.repo.DeleteAccrualJob(ctx, job.OrderNumber, p.owner)
+	case status == job.Order.Status:
+		return p.retry(ctx, job, nil)
+	}
+
+	if err = p.repo.UpdateOrderStatus(ctx, job.OrderNumber, status); err != nil {
+		return err
+	}
+	if isFinal(status) {
+		return p.repo.DeleteAccrualJob(ctx, job.OrderNumber, p.owner)
+	}
+
+	job.Attempts = 0
+	return p.retry(ctx, job, nil)
+}
+
+// retry schedules the next poll of an order that is still pending with an
+// exponential backoff, or flags it for manual review once it is older than
+// the max age.
+func (p *Pool) retry(ctx context.Context, job model.AccrualJob, cause error) error {
+	if p.maxAge > 0 && time.Since(job.Order.UploadedAt) > p.maxAge {
+		job.Attempts++
+		job.LeaseOwner = p.owner
+		job.LastError = errorText(cause)
+		util.GetLogger().Warnf("order %s is pending for longer than %s, flagged for review", job.OrderNumber, p.maxAge)
+		return p.repo.FlagAccrualJob(ctx, &job)
+	}
+
+	delay := p.backoff.delay(job.Attempts)
+	job.Attempts++
+
+	return p.reschedule(ctx, job, delay, cause)
+}
+
+// reschedule releases the lease of the job and schedules its next poll after
+// delay. Attempts is left as is, so waits caused by the accrual system as a
+// whole don't grow the backoff of the order.
+func (p *Pool) reschedule(ctx context.Context, job model.AccrualJob, delay time.Duration, cause error) error {
+	job.NextAttemptAt = time.Now().Add(delay)
+	job.LeaseOwner = p.owner
+	job.LastError = errorText(cause)
+
+	return p.repo.RetryAccrualJob(ctx, &job)
+}
+
+func isFinal(status model.OrderStatus) bool {
+	return status == model.OrderStatusProcessed || status == model.OrderStatusInvalid
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -1,13 +1,20 @@
 package util
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,83 +24,315 @@ var (
 	cfgPath = "configuration/config.yaml"
 )
 
+// Config is assembled in layers, each overriding the previous one:
+// defaults, YAML file, command line flags, environment variables.
 type Config struct {
 	Logger   LoggerCfg `yaml:"Logger"`
 	Server   Server    `yaml:"Server"`
 	Postgres Postgres  `yaml:"Postgres"`
 	Auth     Auth      `yaml:"Auth"`
+	Accrual  Accrual   `yaml:"Accrual"`
 }
 
 type Auth struct {
-	SecretKey  string `yaml:"SecretKey"`
-	CookieName string `yaml:"CookieName"`
+	// SecretKey signs tokens when no Keys are configured. Tokens without a
+	// kid header are verified with it while no key of Keys is active, and
+	// after that only until SecretKeyRetireAt.
+	SecretKey         string         `yaml:"SecretKey" env:"AUTH_SECRET_KEY" flag:"auth-secret-key"`
+	SecretKeyRetireAt time.Time      `yaml:"SecretKeyRetireAt" env:"AUTH_SECRET_KEY_RETIRE_AT" flag:"auth-secret-key-retire-at"`
+	Keys              []SigningKey   `yaml:"Keys"`
+	CookieName        string         `yaml:"CookieName" env:"AUTH_COOKIE_NAME" flag:"auth-cookie-name"`
+	RefreshCookieName string         `yaml:"RefreshCookieName" env:"AUTH_REFRESH_COOKIE_NAME" flag:"auth-refresh-cookie-name"`
+	AccessTokenTTL    int64          `yaml:"AccessTokenTTL" env:"AUTH_ACCESS_TOKEN_TTL" flag:"auth-access-token-ttl"`
+	RefreshTokenTTL   int64          `yaml:"RefreshTokenTTL" env:"AUTH_REFRESH_TOKEN_TTL" flag:"auth-refresh-token-ttl"`
+	PreAuthTokenTTL   int64          `yaml:"PreAuthTokenTTL" env:"AUTH_PRE_AUTH_TOKEN_TTL" flag:"auth-pre-auth-token-ttl"`
+	TOTPIssuer        string         `yaml:"TOTPIssuer" env:"AUTH_TOTP_ISSUER" flag:"auth-totp-issuer"`
+	Cookie            Cookie         `yaml:"Cookie"`
+	Lockout           Lockout        `yaml:"Lockout"`
+	PasswordPolicy    PasswordPolicy `yaml:"PasswordPolicy"`
+	// AdminLogins are granted the admin role at startup if their accounts
+	// exist. Registration never grants it.
+	AdminLogins []string `yaml:"AdminLogins" env:"AUTH_ADMIN_LOGINS" flag:"auth-admin-logins"`
 }
 
+// Cookie sets the attributes of every auth cookie. SameSite is one of lax,
+// strict or none; none is only accepted by browsers together with Secure.
+// An empty Domain scopes the cookies to the exact host.
+type Cookie struct {
+	Secure   bool   `yaml:"Secure" env:"AUTH_COOKIE_SECURE" flag:"auth-cookie-secure"`
+	SameSite string `yaml:"SameSite" env:"AUTH_COOKIE_SAME_SITE" flag:"auth-cookie-same-site"`
+	Domain   string `yaml:"Domain" env:"AUTH_COOKIE_DOMAIN" flag:"auth-cookie-domain"`
+}
+
+const (
+	SameSiteLax    = "lax"
+	SameSiteStrict = "strict"
+	SameSiteNone   = "none"
+)
+
+// PasswordPolicy applies to new passwords. MinLength counts characters,
+// MaxLength counts bytes since bcrypt ignores everything past 72 of them.
+// DenyListFile, if set, lists breached or common passwords that are
+// rejected, one per line.
+type PasswordPolicy struct {
+	MinLength    int    `yaml:"MinLength" env:"AUTH_PASSWORD_MIN_LENGTH" flag:"auth-password-min-length"`
+	MaxLength    int    `yaml:"MaxLength" env:"AUTH_PASSWORD_MAX_LENGTH" flag:"auth-password-max-length"`
+	DenyListFile string `yaml:"DenyListFile" env:"AUTH_PASSWORD_DENY_LIST" flag:"auth-password-deny-list"`
+}
+
+// Lockout limits failed logins per login and per client IP. After Threshold
+// failures the key is locked for BaseDelay seconds, doubling with each further
+// failure up to MaxDelay. Failures are forgotten Window seconds after the last
+// failure or lock.
+type Lockout struct {
+	Threshold   int   `yaml:"Threshold" env:"AUTH_LOCKOUT_THRESHOLD" flag:"auth-lockout-threshold"`
+	IPThreshold int   `yaml:"IPThreshold" env:"AUTH_LOCKOUT_IP_THRESHOLD" flag:"auth-lockout-ip-threshold"`
+	BaseDelay   int64 `yaml:"BaseDelay" env:"AUTH_LOCKOUT_BASE_DELAY" flag:"auth-lockout-base-delay"`
+	MaxDelay    int64 `yaml:"MaxDelay" env:"AUTH_LOCKOUT_MAX_DELAY" flag:"auth-lockout-max-delay"`
+	Window      int64 `yaml:"Window" env:"AUTH_LOCKOUT_WINDOW" flag:"auth-lockout-window"`
+}
+
+// SigningKey is one key of the token keyset. The active signing key is the
+// most recent one whose ActiveFrom has passed; a key keeps verifying tokens
+// until RetireAt, which gives a grace period after rotation.
+//
+// HS256 keys use Secret. RS256 and EdDSA keys are loaded from the PEM encoded
+// PrivateKeyFile and their public halves are published as JWKS.
+type SigningKey struct {
+	ID             string    `yaml:"ID"`
+	Algorithm      string    `yaml:"Algorithm"`
+	Secret         string    `yaml:"Secret"`
+	PrivateKeyFile string    `yaml:"PrivateKeyFile"`
+	ActiveFrom     time.Time `yaml:"ActiveFrom"`
+	RetireAt       time.Time `yaml:"RetireAt"`
+}
+
+const (
+	SigningAlgorithmHS256 = "HS256"
+	SigningAlgorithmRS256 = "RS256"
+	SigningAlgorithmEdDSA = "EdDSA"
+)
+
 type Server struct {
-	ServerAddress string `yaml:"-"`
-	Address       string `yaml:"Address"`
-	Port          uint   `yaml:"Port"`
-	RTimeout      int64  `yaml:"RTimeout"`
-	WTimeout      int64  `yaml:"WTimeout"`
+	ServerAddress string `yaml:"ServerAddress" env:"RUN_ADDRESS" deprecatedEnv:"SERVER_ADDRESS" flag:"a"`
+	Address       string `yaml:"Address" env:"SERVER_HOST" flag:"server-host"`
+	Port          uint   `yaml:"Port" env:"SERVER_PORT" flag:"server-port"`
+	RTimeout      int64  `yaml:"RTimeout" env:"SERVER_READ_TIMEOUT" flag:"server-read-timeout"`
+	WTimeout      int64  `yaml:"WTimeout" env:"SERVER_WRITE_TIMEOUT" flag:"server-write-timeout"`
+	// TrustedProxies lists the IPs or CIDRs of reverse proxies whose
+	// X-Forwarded-For header is believed. Empty means the client IP is
+	// always the peer address, so it can't be spoofed.
+	TrustedProxies []string `yaml:"TrustedProxies" env:"SERVER_TRUSTED_PROXIES" flag:"server-trusted-proxies"`
 }
 
+// Postgres storage is used when ConnString is set, otherwise the service
+// falls back to in-memory storage.
 type Postgres struct {
-	ConnString      string   `yaml:"-"`
-	DriverName      string   `yaml:"DriverName"`
-	Address         string   `yaml:"Address"`
-	DBName          string   `yaml:"DBName"`
-	User            string   `yaml:"User"`
-	Password        string   `yaml:"Password"`
-	MaxConn         int      `yaml:"MaxConn"`
-	MaxConnLifeTime int64    `yaml:"MaxConnLifeTime"`
-	Trace           bool     `yaml:"Trace"`
-	MakeMigration   bool     `yaml:"MakeMigration"`
-	SQLKeyWords     []string `yaml:"SQLKeyWords"`
-}
-
-func parseConfig(st interface{}, cfgPath string) {
-	f, err := os.Open(cfgPath)
-	if err != nil {
-		log.Fatal(errors.WithMessage(err, "error occurred while opening cfg file"))
+	ConnString      string   `yaml:"ConnString" env:"DATABASE_URI" deprecatedEnv:"DATABASE_DSN" flag:"d"`
+	DriverName      string   `yaml:"DriverName" env:"POSTGRES_DRIVER_NAME" flag:"postgres-driver-name"`
+	Address         string   `yaml:"Address" env:"POSTGRES_ADDRESS" flag:"postgres-address"`
+	DBName          string   `yaml:"DBName" env:"POSTGRES_DB_NAME" flag:"postgres-db-name"`
+	User            string   `yaml:"User" env:"POSTGRES_USER" flag:"postgres-user"`
+	Password        string   `yaml:"Password" env:"POSTGRES_PASSWORD" flag:"postgres-password"`
+	MaxConn         int      `yaml:"MaxConn" env:"POSTGRES_MAX_CONN" flag:"postgres-max-conn"`
+	MaxConnLifeTime int64    `yaml:"MaxConnLifeTime" env:"POSTGRES_MAX_CONN_LIFETIME" flag:"postgres-max-conn-lifetime"`
+	Trace           bool     `yaml:"Trace" env:"POSTGRES_TRACE" flag:"postgres-trace"`
+	MakeMigration   bool     `yaml:"MakeMigration" env:"POSTGRES_MAKE_MIGRATION" flag:"postgres-make-migration"`
+	SQLKeyWords     []string `yaml:"SQLKeyWords" env:"POSTGRES_SQL_KEYWORDS" flag:"postgres-sql-keywords"`
+}
+
+type Accrual struct {
+	Address        string `yaml:"Address" env:"ACCRUAL_SYSTEM_ADDRESS" flag:"r"`
+	Workers        int    `yaml:"Workers" env:"ACCRUAL_WORKERS" flag:"accrual-workers"`
+	BatchSize      int    `yaml:"BatchSize" env:"ACCRUAL_BATCH_SIZE" flag:"accrual-batch-size"`
+	PollInterval   int64  `yaml:"PollInterval" env:"ACCRUAL_POLL_INTERVAL" flag:"accrual-poll-interval"`
+	RequestTimeout int64  `yaml:"RequestTimeout" env:"ACCRUAL_REQUEST_TIMEOUT" flag:"accrual-request-timeout"`
+	ConnectTimeout int64  `yaml:"ConnectTimeout" env:"ACCRUAL_CONNECT_TIMEOUT" flag:"accrual-connect-timeout"`
+	// VisibilityTimeout is how long a worker holds the jobs it leased. Jobs
+	// of a worker that died are picked up by others once it runs out. A
+	// batch is never larger than the rate limit allows to poll within it.
+	VisibilityTimeout int64 `yaml:"VisibilityTimeout" env:"ACCRUAL_VISIBILITY_TIMEOUT" flag:"accrual-visibility-timeout"`
+	// CallbackSecret signs the order updates the accrual system pushes to
+	// /internal/accrual/callback. The endpoint is disabled while it is empty.
+	CallbackSecret string `yaml:"CallbackSecret" env:"ACCRUAL_CALLBACK_SECRET" flag:"accrual-callback-secret"`
+	// RateLimit is the initial number of requests per minute, 0 means no
+	// limit until the accrual system reports one in a 429 response.
+	RateLimit int     `yaml:"RateLimit" env:"ACCRUAL_RATE_LIMIT" flag:"accrual-rate-limit"`
+	Breaker   Breaker `yaml:"Breaker"`
+	Backoff   Backoff `yaml:"Backoff"`
+}
+
+// Backoff spaces out polls of an order whose status does not change: the
+// delay starts at Base seconds and doubles with every poll up to Max, with
+// jitter. An order still pending MaxAge seconds after upload is flagged for
+// manual review instead of being polled further; 0 polls forever.
+type Backoff struct {
+	Base   int64 `yaml:"Base" env:"ACCRUAL_BACKOFF_BASE" flag:"accrual-backoff-base"`
+	Max    int64 `yaml:"Max" env:"ACCRUAL_BACKOFF_MAX" flag:"accrual-backoff-max"`
+	MaxAge int64 `yaml:"MaxAge" env:"ACCRUAL_MAX_AGE" flag:"accrual-max-age"`
+}
+
+// Breaker stops requests to the accrual system for OpenTimeout seconds after
+// Threshold consecutive 5xx responses or timeouts.
+type Breaker struct {
+	Threshold   int   `yaml:"Threshold" env:"ACCRUAL_BREAKER_THRESHOLD" flag:"accrual-breaker-threshold"`
+	OpenTimeout int64 `yaml:"OpenTimeout" env:"ACCRUAL_BREAKER_OPEN_TIMEOUT" flag:"accrual-breaker-open-timeout"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Logger: LoggerCfg{
+			Level: logrus.InfoLevel,
+		},
+		Server: Server{
+			Address:  "localhost",
+			Port:     8080,
+			RTimeout: 10,
+			WTimeout: 10,
+		},
+		Postgres: Postgres{
+			DriverName:      "postgres",
+			MaxConn:         10,
+			MaxConnLifeTime: 60,
+			MakeMigration:   true,
+		},
+		Auth: Auth{
+			CookieName:        "user_id",
+			RefreshCookieName: "refresh_token",
+			AccessTokenTTL:    15 * 60,
+			RefreshTokenTTL:   30 * 24 * 60 * 60,
+			PreAuthTokenTTL:   5 * 60,
+			TOTPIssuer:        "Gophermart",
+			Cookie: Cookie{
+				SameSite: SameSiteLax,
+			},
+			Lockout: Lockout{
+				Threshold:   5,
+				IPThreshold: 50,
+				BaseDelay:   30,
+				MaxDelay:    60 * 60,
+				Window:      15 * 60,
+			},
+			PasswordPolicy: PasswordPolicy{
+				MinLength: 6,
+				MaxLength: 72,
+			},
+		},
+		Accrual: Accrual{
+			Workers:           4,
+			BatchSize:         50,
+			PollInterval:      1,
+			RequestTimeout:    5,
+			ConnectTimeout:    2,
+			VisibilityTimeout: 30,
+			Breaker: Breaker{
+				Threshold:   5,
+				OpenTimeout: 30,
+			},
+			Backoff: Backoff{
+				Base:   1,
+				Max:    60 * 60,
+				MaxAge: 7 * 24 * 60 * 60,
+			},
+		},
 	}
+}
 
-	fi, err := f.Stat()
+func parseConfig(st interface{}, cfgPath string) error {
+	data, err := os.ReadFile(cfgPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
-		log.Fatal(errors.WithMessage(err, "error occurred while getting file stats"))
+		return errors.WithMessage(err, "error occurred while reading cfg file")
 	}
 
-	data := make([]byte, fi.Size())
-	_, err = f.Read(data)
-	if err != nil {
-		log.Fatal(errors.WithMessage(err, "error occurred while reading data"))
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(st)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.WithMessage(err, "error occurred while unmashaling data")
 	}
 
-	err = yaml.Unmarshal(data, st)
-	if err != nil {
-		log.Fatal(errors.WithMessage(err, "error occurred while unmashaling data"))
+	return nil
+}
+
+// LoadConfig builds the configuration from all layers and validates it.
+// The YAML path may be overridden with CONFIG_PATH.
+func LoadConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	conf := defaultConfig()
+	if err := parseConfig(&conf, configPath()); err != nil {
+		return nil, err
 	}
+
+	bindFlags(fs, &conf)
+	if err := fs.Parse(args); err != nil {
+		return nil, errors.WithMessage(err, "error occurred while parsing flags")
+	}
+
+	problems := applyEnv(&conf)
+
+	conf.fillDerived()
+
+	var vErr *ValidationError
+	if err := conf.Validate(); errors.As(err, &vErr) {
+		problems = append(problems, vErr.Problems...)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	return &conf, nil
 }
 
-func GetConfig() *Config {
-	onceCFG.Do(func() {
-		var (
-			conf Config
-		)
-		parseConfig(&conf, cfgPath)
+// LoadAuthKeys re-reads the signing keyset from the YAML file, so keys can
+// be added or promoted without restarting the service.
+func LoadAuthKeys() ([]SigningKey, error) {
+	conf := defaultConfig()
+	if err := parseConfig(&conf, configPath()); err != nil {
+		return nil, err
+	}
 
-		flag.StringVar(&conf.Server.ServerAddress, "a", fmt.Sprintf("%s:%d", conf.Server.Address, conf.Server.Port), "HTTP server address")
-		flag.StringVar(&conf.Postgres.ConnString, "d", fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", conf.Postgres.User, conf.Postgres.Password, conf.Postgres.Address, conf.Postgres.DBName), "Database connect string")
-		flag.Parse()
+	if problems := validateSigningKeys(conf.Auth.Keys); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
 
-		if envAddr, exists := os.LookupEnv("SERVER_ADDRESS"); exists {
-			conf.Server.ServerAddress = envAddr
-		}
+	return conf.Auth.Keys, nil
+}
 
-		if envDB, exists := os.LookupEnv("DATABASE_DSN"); exists {
-			conf.Postgres.ConnString = envDB
+func configPath() string {
+	if envPath, exists := os.LookupEnv("CONFIG_PATH"); exists {
+		return envPath
+	}
+
+	return cfgPath
+}
+
+// fillDerived composes the addresses that were not given explicitly from
+// their parts.
+func (c *Config) fillDerived() {
+	if c.Server.ServerAddress == "" {
+		c.Server.ServerAddress = net.JoinHostPort(c.Server.Address, strconv.FormatUint(uint64(c.Server.Port), 10))
+	}
+
+	if c.Accrual.Address != "" && !strings.Contains(c.Accrual.Address, "://") {
+		c.Accrual.Address = "http://" + c.Accrual.Address
+	}
+
+	if c.Postgres.ConnString == "" && c.Postgres.Address != "" {
+		c.Postgres.ConnString = fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable",
+			c.Postgres.User, c.Postgres.Password, c.Postgres.Address, c.Postgres.DBName)
+	}
+}
+
+func GetConfig() *Config {
+	onceCFG.Do(func() {
+		conf, err := LoadConfig(flag.CommandLine, os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		config = &conf
+		config = conf
 	})
 
 	if config == nil {
@@ -0,0 +1,140 @@
+package util
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// configField is a flag.Value backed by a single leaf field of Config.
+type configField struct {
+	value reflect.Value
+}
+
+func (f configField) String() string {
+	if !f.value.IsValid() {
+		return ""
+	}
+	if f.value.Kind() == reflect.Slice {
+		return strings.Join(f.value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(f.value.Interface())
+}
+
+func (f configField) Set(s string) error {
+	return setField(f.value, s)
+}
+
+func (f configField) IsBoolFlag() bool {
+	return f.value.Kind() == reflect.Bool
+}
+
+// bindFlags registers a flag for every Config field carrying a flag tag.
+func bindFlags(fs *flag.FlagSet, conf *Config) {
+	walkConfig(reflect.ValueOf(conf).Elem(), "", func(v reflect.Value, field reflect.StructField, path string) {
+		if name := field.Tag.Get("flag"); name != "" {
+			fs.Var(configField{value: v}, name, path)
+		}
+	})
+}
+
+// applyEnv overrides Config fields from the environment variables named in
+// their env tags and returns the values that could not be parsed. A variable
+// named in a deprecatedEnv tag is still honoured, with a warning, when the
+// current one is not set.
+func applyEnv(conf *Config) []string {
+	var problems []string
+	walkConfig(reflect.ValueOf(conf).Elem(), "", func(v reflect.Value, field reflect.StructField, path string) {
+		name := field.Tag.Get("env")
+		if name == "" {
+			return
+		}
+		value, exists := os.LookupEnv(name)
+		if old := field.Tag.Get("deprecatedEnv"); !exists && old != "" {
+			if value, exists = os.LookupEnv(old); exists {
+				log.Printf("warning: %s is deprecated, use %s instead", old, name)
+				name = old
+			}
+		}
+		if exists {
+			if err := setField(v, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s (%s): %v", path, name, err))
+			}
+		}
+	})
+
+	return problems
+}
+
+func walkConfig(v reflect.Value, prefix string, fn func(reflect.Value, reflect.StructField, string)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		path := field.Name
+		if prefix != "" {
+			path = prefix + "." + field.Name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && !isTextField(fv) {
+			walkConfig(fv, path, fn)
+			continue
+		}
+		fn(fv, field, path)
+	}
+}
+
+// isTextField reports whether the field parses itself from text, like
+// time.Time, and so is a leaf even though it is a struct.
+func isTextField(v reflect.Value) bool {
+	_, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+func setField(v reflect.Value, s string) error {
+	if isTextField(v) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		parts := make([]string, 0)
+		for _, part := range strings.Split(s, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		return errors.Errorf("unsupported config field type %s", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigTimeField(t *testing.T) {
+	t.Setenv("CONFIG_PATH", filepath.Join(t.TempDir(), "missing.yaml"))
+	t.Setenv("AUTH_SECRET_KEY", "secret")
+	t.Setenv("ACCRUAL_SYSTEM_ADDRESS", "localhost:8081")
+	retireAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("AUTH_SECRET_KEY_RETIRE_AT", retireAt.Format(time.RFC3339))
+
+		conf, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+		require.NoError(t, err)
+		assert.True(t, retireAt.Equal(conf.Auth.SecretKeyRetireAt))
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		args := []string{"-auth-secret-key-retire-at", retireAt.Format(time.RFC3339)}
+
+		conf, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), args)
+		require.NoError(t, err)
+		assert.True(t, retireAt.Equal(conf.Auth.SecretKeyRetireAt))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("AUTH_SECRET_KEY_RETIRE_AT", "tomorrow")
+
+		_, err := LoadConfig(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+		var vErr *ValidationError
+		require.ErrorAs(t, err, &vErr)
+		assert.Contains(t, err.Error(), "Auth.SecretKeyRetireAt (AUTH_SECRET_KEY_RETIRE_AT)")
+	})
+}
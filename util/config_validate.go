@@ -0,0 +1,202 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ValidationError lists every problem found in the configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the whole configuration and reports all invalid fields at
+// once.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if c.Logger.File.Enabled && c.Logger.File.FileName == "" {
+		add("Logger.File.FileName", "must be set when file logging is enabled")
+	}
+	if c.Logger.SysLog.Enabled && c.Logger.SysLog.Network != "" && c.Logger.SysLog.Address == "" {
+		add("Logger.SysLog.Address", "must be set when network is given")
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.ServerAddress); err != nil {
+		add("Server.ServerAddress", "invalid host:port %q: %v", c.Server.ServerAddress, err)
+	}
+	if c.Server.RTimeout < 0 {
+		add("Server.RTimeout", "must not be negative")
+	}
+	if c.Server.WTimeout < 0 {
+		add("Server.WTimeout", "must not be negative")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("Server.TrustedProxies", "invalid IP or CIDR %q", proxy)
+		}
+	}
+
+	if _, err := url.Parse(c.Postgres.ConnString); err != nil {
+		add("Postgres.ConnString", "invalid database URI: %v", err)
+	}
+	if c.Postgres.MaxConn < 0 {
+		add("Postgres.MaxConn", "must not be negative")
+	}
+	if c.Postgres.MaxConnLifeTime < 0 {
+		add("Postgres.MaxConnLifeTime", "must not be negative")
+	}
+
+	if c.Auth.SecretKey == "" && len(c.Auth.Keys) == 0 {
+		add("Auth.SecretKey", "is required when no Auth.Keys are configured")
+	}
+	if !c.Auth.SecretKeyRetireAt.IsZero() && c.Auth.SecretKey == "" {
+		add("Auth.SecretKeyRetireAt", "requires Auth.SecretKey")
+	}
+	problems = append(problems, validateSigningKeys(c.Auth.Keys)...)
+	if c.Auth.CookieName == "" {
+		add("Auth.CookieName", "is required")
+	}
+	if c.Auth.RefreshCookieName == "" || c.Auth.RefreshCookieName == c.Auth.CookieName {
+		add("Auth.RefreshCookieName", "is required and must differ from CookieName")
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		add("Auth.AccessTokenTTL", "must be positive")
+	}
+	if c.Auth.RefreshTokenTTL <= c.Auth.AccessTokenTTL {
+		add("Auth.RefreshTokenTTL", "must be greater than AccessTokenTTL")
+	}
+	if c.Auth.PreAuthTokenTTL <= 0 {
+		add("Auth.PreAuthTokenTTL", "must be positive")
+	}
+	if c.Auth.TOTPIssuer == "" {
+		add("Auth.TOTPIssuer", "is required")
+	}
+	switch strings.ToLower(c.Auth.Cookie.SameSite) {
+	case SameSiteLax, SameSiteStrict:
+	case SameSiteNone:
+		if !c.Auth.Cookie.Secure {
+			add("Auth.Cookie.SameSite", "none requires Secure")
+		}
+	default:
+		add("Auth.Cookie.SameSite", "must be one of lax, strict, none, got %q", c.Auth.Cookie.SameSite)
+	}
+	if c.Auth.Lockout.Threshold <= 0 {
+		add("Auth.Lockout.Threshold", "must be positive")
+	}
+	if c.Auth.Lockout.IPThreshold < c.Auth.Lockout.Threshold {
+		add("Auth.Lockout.IPThreshold", "must not be less than Threshold")
+	}
+	if c.Auth.Lockout.BaseDelay <= 0 {
+		add("Auth.Lockout.BaseDelay", "must be positive")
+	}
+	if c.Auth.Lockout.MaxDelay < c.Auth.Lockout.BaseDelay {
+		add("Auth.Lockout.MaxDelay", "must not be less than BaseDelay")
+	}
+	if c.Auth.Lockout.Window <= 0 {
+		add("Auth.Lockout.Window", "must be positive")
+	}
+	if c.Auth.PasswordPolicy.MinLength <= 0 {
+		add("Auth.PasswordPolicy.MinLength", "must be positive")
+	}
+	if c.Auth.PasswordPolicy.MaxLength < c.Auth.PasswordPolicy.MinLength || c.Auth.PasswordPolicy.MaxLength > 72 {
+		add("Auth.PasswordPolicy.MaxLength", "must be between MinLength and 72")
+	}
+	if file := c.Auth.PasswordPolicy.DenyListFile; file != "" {
+		if _, err := os.Stat(file); err != nil {
+			add("Auth.PasswordPolicy.DenyListFile", "%v", err)
+		}
+	}
+
+	if c.Accrual.Address == "" {
+		add("Accrual.Address", "accrual system address is required")
+	} else if u, err := url.Parse(c.Accrual.Address); err != nil || u.Host == "" {
+		add("Accrual.Address", "invalid URL %q", c.Accrual.Address)
+	}
+	if c.Accrual.Workers <= 0 {
+		add("Accrual.Workers", "must be positive")
+	}
+	if c.Accrual.BatchSize <= 0 {
+		add("Accrual.BatchSize", "must be positive")
+	}
+	if c.Accrual.PollInterval <= 0 {
+		add("Accrual.PollInterval", "must be positive")
+	}
+	if c.Accrual.RequestTimeout <= 0 {
+		add("Accrual.RequestTimeout", "must be positive")
+	}
+	if c.Accrual.ConnectTimeout <= 0 {
+		add("Accrual.ConnectTimeout", "must be positive")
+	}
+	if c.Accrual.VisibilityTimeout <= c.Accrual.RequestTimeout {
+		add("Accrual.VisibilityTimeout", "must be greater than RequestTimeout")
+	}
+	if c.Accrual.RateLimit < 0 {
+		add("Accrual.RateLimit", "must not be negative")
+	}
+	if c.Accrual.Breaker.Threshold <= 0 {
+		add("Accrual.Breaker.Threshold", "must be positive")
+	}
+	if c.Accrual.Breaker.OpenTimeout <= 0 {
+		add("Accrual.Breaker.OpenTimeout", "must be positive")
+	}
+	if c.Accrual.Backoff.Base <= 0 {
+		add("Accrual.Backoff.Base", "must be positive")
+	}
+	if c.Accrual.Backoff.Max < c.Accrual.Backoff.Base {
+		add("Accrual.Backoff.Max", "must not be less than Base")
+	}
+	if c.Accrual.Backoff.MaxAge < 0 {
+		add("Accrual.Backoff.MaxAge", "must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func validateSigningKeys(keys []SigningKey) []string {
+	var problems []string
+	seen := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		field := fmt.Sprintf("Auth.Keys[%d]", i)
+		if key.ID == "" {
+			problems = append(problems, field+".ID: is required")
+		} else if seen[key.ID] {
+			problems = append(problems, fmt.Sprintf("%s.ID: duplicate key id %q", field, key.ID))
+		}
+		seen[key.ID] = true
+
+		switch key.Algorithm {
+		case "", SigningAlgorithmHS256:
+			if key.Secret == "" {
+				problems = append(problems, field+".Secret: is required for HS256")
+			}
+		case SigningAlgorithmRS256, SigningAlgorithmEdDSA:
+			if key.PrivateKeyFile == "" {
+				problems = append(problems, field+".PrivateKeyFile: is required for "+key.Algorithm)
+			} else if _, err := os.Stat(key.PrivateKeyFile); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.PrivateKeyFile: %v", field, err))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s.Algorithm: unsupported algorithm %q", field, key.Algorithm))
+		}
+		if !key.RetireAt.IsZero() && !key.RetireAt.After(key.ActiveFrom) {
+			problems = append(problems, field+".RetireAt: must be after ActiveFrom")
+		}
+	}
+
+	return problems
+}
@@ -8,24 +8,24 @@ import (
 )
 
 type LoggerCfg struct {
-	Level  log.Level `yaml:"Level"`
+	Level  log.Level `yaml:"Level" env:"LOG_LEVEL" flag:"log-level"`
 	File   LogFile   `yaml:"File"`
-	SysLog SysLog    `yaml:"Syslog"`
+	SysLog SysLog    `yaml:"SysLog"`
 }
 
 type LogFile struct {
-	Enabled    bool   `yaml:"Enabled"`
-	FileName   string `yaml:"FileName"`
-	MaxSize    int    `yaml:"MaxSize"`
-	MaxBackups int    `yaml:"MaxBackups"`
-	MaxAge     int    `yaml:"MaxAge"`
+	Enabled    bool   `yaml:"Enabled" env:"LOG_FILE_ENABLED" flag:"log-file-enabled"`
+	FileName   string `yaml:"FileName" env:"LOG_FILE_NAME" flag:"log-file-name"`
+	MaxSize    int    `yaml:"MaxSize" env:"LOG_FILE_MAX_SIZE" flag:"log-file-max-size"`
+	MaxBackups int    `yaml:"MaxBackups" env:"LOG_FILE_MAX_BACKUPS" flag:"log-file-max-backups"`
+	MaxAge     int    `yaml:"MaxAge" env:"LOG_FILE_MAX_AGE" flag:"log-file-max-age"`
 }
 
 type SysLog struct {
-	Enabled bool   `yaml:"Enabled"`
-	Address string `yaml:"Address"`
-	Network string `yaml:"Network"`
-	Tag     string `yaml:"Tag"`
+	Enabled bool   `yaml:"Enabled" env:"LOG_SYSLOG_ENABLED" flag:"log-syslog-enabled"`
+	Address string `yaml:"Address" env:"LOG_SYSLOG_ADDRESS" flag:"log-syslog-address"`
+	Network string `yaml:"Network" env:"LOG_SYSLOG_NETWORK" flag:"log-syslog-network"`
+	Tag     string `yaml:"Tag" env:"LOG_SYSLOG_TAG" flag:"log-syslog-tag"`
 }
 
 var logger *log.Logger
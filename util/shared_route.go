@@ -32,6 +32,14 @@ func GetHealthcheckRoute(r *gin.Engine) {
 	})
 }
 
+// GetJWKSRoute publishes the public token keys so other services can verify
+// tokens without sharing a secret.
+func GetJWKSRoute(r *gin.Engine, jwks func() interface{}) {
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, jwks())
+	})
+}
+
 func GetRouteList(r *gin.Engine) {
 	r.GET("/routes", func(c *gin.Context) {
 		resp := make([]route, 0)